@@ -0,0 +1,217 @@
+package bilateral
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+const (
+	// Default stylization parameters, after Winnemöller et al. "Real-Time Video Abstraction".
+	defaultAbstractionIterations = 4
+	defaultLevels                = 8
+	defaultSharpness             = 50
+	defaultEdgeSigma             = 1
+	defaultEdgeTau               = 0.98
+	defaultEdgeSharpness         = 50
+	// Ratio between the outer and the inner sigmas of the difference-of-Gaussians.
+	dogRatio = 1.6
+)
+
+// An Abstraction runs a FastBilateral filter several times, feeding each result back as input
+// without quantization, then optionally quantizes the luminance and overlays difference-of-Gaussians edges.
+// It produces stylized "cartoon" images.
+type Abstraction struct {
+	Image      image.Image
+	SigmaRange float64
+	SigmaSpace float64
+	// Iterations is the number of bilateral passes.
+	Iterations int
+	// RangeDecay is the factor applied to SigmaRange after each pass, 1 when zero.
+	RangeDecay float64
+	// Levels is the number of luminance quantization levels, the quantization is disabled when zero.
+	Levels int
+	// Sharpness is the slope of the soft quantization between two levels, the quantization is hard when zero.
+	Sharpness float64
+	// EdgeSigma is the inner sigma of the difference-of-Gaussians in pixels, the edges are disabled when zero.
+	EdgeSigma float64
+	// EdgeTau is the sensitivity of the edge detection, close to 1.
+	EdgeTau float64
+	// EdgeSharpness is the slope of the edges' darkening.
+	EdgeSharpness float64
+	result        *FloatImage
+}
+
+// NewIterative instanciates a new Abstraction running the given number of bilateral passes,
+// without quantization and edges.
+func NewIterative(m image.Image, sigmaSpace, sigmaRange float64, iterations int) *Abstraction {
+	return &Abstraction{
+		Image:      m,
+		SigmaRange: sigmaRange,
+		SigmaSpace: sigmaSpace,
+		Iterations: iterations,
+		RangeDecay: 1,
+	}
+}
+
+// NewAbstraction instanciates a new Abstraction with the default stylization parameters.
+func NewAbstraction(m image.Image, sigmaSpace, sigmaRange float64) *Abstraction {
+	a := NewIterative(m, sigmaSpace, sigmaRange, defaultAbstractionIterations)
+	a.Levels = defaultLevels
+	a.Sharpness = defaultSharpness
+	a.EdgeSigma = defaultEdgeSigma
+	a.EdgeTau = defaultEdgeTau
+	a.EdgeSharpness = defaultEdgeSharpness
+	return a
+}
+
+// Execute runs the bilateral passes and the stylization.
+func (a *Abstraction) Execute() error {
+	m := ToFloatImage(a.Image)
+	sigmaRange := a.SigmaRange
+	for i := 0; i < a.Iterations; i++ {
+		f := New(m, a.SigmaSpace, sigmaRange)
+		if err := f.Execute(); err != nil {
+			return err
+		}
+		m = f.ResultFloat()
+
+		if a.RangeDecay > 0 {
+			sigmaRange *= a.RangeDecay
+		}
+	}
+
+	if a.Levels > 0 || a.EdgeSigma > 0 {
+		m = a.stylize(m)
+	}
+	a.result = m
+	return nil
+}
+
+// ColorModel returns the Image's color model.
+func (a *Abstraction) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// Bounds implements image.Image interface.
+func (a *Abstraction) Bounds() image.Rectangle {
+	return a.Image.Bounds()
+}
+
+// At returns the stylized color at the given coordinates.
+func (a *Abstraction) At(x, y int) color.Color {
+	return util.RGBA8(a.result.FloatAt(x, y))
+}
+
+// ResultImage returns the stylized image.
+func (a *Abstraction) ResultImage() image.Image {
+	d := a.Image.Bounds()
+	dst := image.NewRGBA(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			dst.Set(x, y, a.At(x, y))
+		}
+	}
+	return dst
+}
+
+// ResultFloat returns the stylized image without quantization.
+func (a *Abstraction) ResultFloat() *FloatImage {
+	return a.result
+}
+
+// stylize quantizes the luminance and darkens the edges of the given image.
+func (a *Abstraction) stylize(m *FloatImage) *FloatImage {
+	d := m.Bounds()
+	w, h := d.Dx(), d.Dy()
+
+	luminance := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := m.FloatAt(d.Min.X+x, d.Min.Y+y)
+			_, luminance[y*w+x], _ = colorful.LinearRgbToXyz(r, g, b)
+		}
+	}
+
+	var inner, outer []float64
+	if a.EdgeSigma > 0 {
+		inner = gaussianBlur(luminance, w, h, a.EdgeSigma)
+		outer = gaussianBlur(luminance, w, h, dogRatio*a.EdgeSigma)
+	}
+
+	dst := NewFloatImage(d)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			Y2 := luminance[i]
+			if a.Levels > 0 {
+				Y2 = a.quantize(Y2)
+			}
+			if inner != nil {
+				Y2 *= a.edge(inner[i] - a.EdgeTau*outer[i])
+			}
+
+			// Scale the colors to the new luminance, keeping the chromaticity
+			r, g, b, alpha := m.FloatAt(d.Min.X+x, d.Min.Y+y)
+			if Y := luminance[i]; Y > 0 {
+				s := Y2 / Y
+				r, g, b = r*s, g*s, b*s
+			} else {
+				r, g, b = Y2, Y2, Y2
+			}
+			dst.SetFloat(d.Min.X+x, d.Min.Y+y, r, g, b, alpha)
+		}
+	}
+	return dst
+}
+
+// quantize snaps the luminance Y to the center of its level, softly around the levels' boundaries.
+func (a *Abstraction) quantize(Y float64) float64 {
+	step := 1 / float64(a.Levels)
+	if a.Sharpness <= 0 {
+		return (math.Floor(Y/step) + 0.5) * step
+	}
+
+	nearest := math.Floor(Y/step+0.5) * step // Nearest boundary
+	return nearest + step/2*math.Tanh(a.Sharpness*(Y-nearest))
+}
+
+// edge returns the darkening factor of the given difference-of-Gaussians response.
+func (a *Abstraction) edge(dog float64) float64 {
+	if dog > 0 {
+		return 1
+	}
+	return 1 + math.Tanh(a.EdgeSharpness*dog)
+}
+
+// gaussianBlur returns the given plane blurred by a separable Gaussian of the given sigma in pixels.
+// Pixels out of the plane are clamped to its borders.
+func gaussianBlur(plane []float64, w, h int, sigma float64) []float64 {
+	weights := GaussianKernel(sigma, 1).Weights
+	tmp := make([]float64, len(plane))
+	dst := make([]float64, len(plane))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := weights[0] * plane[y*w+x]
+			for i := 1; i < len(weights); i++ {
+				v += weights[i] * (plane[y*w+util.Clamp(0, w-1, x-i)] + plane[y*w+util.Clamp(0, w-1, x+i)])
+			}
+			tmp[y*w+x] = v
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := weights[0] * tmp[y*w+x]
+			for i := 1; i < len(weights); i++ {
+				v += weights[i] * (tmp[util.Clamp(0, h-1, y-i)*w+x] + tmp[util.Clamp(0, h-1, y+i)*w+x])
+			}
+			dst[y*w+x] = v
+		}
+	}
+	return dst
+}
@@ -0,0 +1,111 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestIterative(t *testing.T) {
+	mi := images["base-gray"]
+
+	filter := bilateral.New(mi, 16, 0.1)
+	filter.Execute()
+	once := filter.ResultFloat()
+
+	filter = bilateral.New(once, 16, 0.05)
+	filter.Execute()
+	expected := filter.ResultFloat()
+
+	iterative := bilateral.NewIterative(mi, 16, 0.1, 2)
+	iterative.RangeDecay = 0.5
+	if err := iterative.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	if !reflect.DeepEqual(iterative.ResultFloat(), expected) {
+		t.Errorf("%s: expected: %v, actual: %v", "ResultFloat", expected.Pix, iterative.ResultFloat().Pix)
+	}
+}
+
+func TestAbstractionLevels(t *testing.T) {
+	mi := images["base-gray"]
+
+	abstraction := bilateral.NewAbstraction(mi, 4, 0.1)
+	abstraction.Levels = 4
+	abstraction.Sharpness = 0
+	abstraction.EdgeSigma = 0
+	if err := abstraction.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	levels := map[uint8]bool{}
+	m := abstraction.ResultImage()
+	for y := 0; y < mi.Bounds().Dy(); y++ {
+		for x := 0; x < mi.Bounds().Dx(); x++ {
+			r, _, _, _ := m.At(x, y).RGBA()
+			levels[uint8(r>>8)] = true
+		}
+	}
+	if len(levels) > 4 {
+		t.Errorf("%s: expected at most: %d, actual: %d", "Levels", 4, len(levels))
+	}
+}
+
+func TestAbstractionEdges(t *testing.T) {
+	// Two flat halves, 40 and 200
+	mi := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			mi.SetGray(x, y, color.Gray{Y: 40})
+			if x >= 16 {
+				mi.SetGray(x, y, color.Gray{Y: 200})
+			}
+		}
+	}
+
+	abstract := func(edgeSigma float64) image.Image {
+		abstraction := bilateral.NewAbstraction(mi, 4, 0.1)
+		abstraction.Iterations = 1
+		abstraction.Sharpness = 0
+		abstraction.EdgeSigma = edgeSigma
+		if err := abstraction.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+		}
+		return abstraction.ResultImage()
+	}
+	quantized := abstract(0)
+	edges := abstract(1)
+
+	gray := func(m image.Image, x, y int) int {
+		r, _, _, _ := m.At(x, y).RGBA()
+		return int(r >> 8)
+	}
+
+	// The flat regions are quantized but not darkened
+	for y := 0; y < 32; y++ {
+		for _, x := range []int{0, 4, 8, 20, 24, 31} {
+			original := int(mi.GrayAt(x, y).Y)
+			expected := gray(quantized, x, y)
+			if expected == original {
+				t.Errorf("%s(%d, %d): expected: != %d, actual: %d", "Quantized", x, y, original, expected)
+			}
+			if actual := gray(edges, x, y); actual != expected {
+				t.Errorf("%s(%d, %d): expected: %d, actual: %d", "Flat", x, y, expected, actual)
+			}
+		}
+	}
+
+	// The dark side of the step is darkened
+	for y := 0; y < 32; y++ {
+		for _, x := range []int{13, 14, 15} {
+			expected := gray(quantized, x, y)
+			if actual := gray(edges, x, y); actual > expected-20 {
+				t.Errorf("%s(%d, %d): expected: < %d, actual: %d", "Edge", x, y, expected-20, actual)
+			}
+		}
+	}
+}
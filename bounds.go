@@ -0,0 +1,112 @@
+package bilateral
+
+import (
+	"image"
+	"math"
+)
+
+// rangeBounds accumulates the range bounds of the scanned pixels.
+// With percentiles, the pixels are scanned in two passes (see passes): the first one finds the absolute bounds
+// and the second one counts the pixels in fixed-size histograms spanning them.
+type rangeBounds struct {
+	gray        bool
+	min         []float64
+	max         []float64
+	percentiles Percentiles
+	// Histogram of each channel over its absolute bounds, set by the second pass.
+	// Nil entries are channels with a single value.
+	histograms []*Histogram
+	// Colour space of the scanned colors.
+	space ColorSpace
+	// progress, when set, is called after each scanned row.
+	progress func()
+}
+
+func newRangeBounds(n int, p Percentiles) *rangeBounds {
+	rb := &rangeBounds{
+		gray:        true,
+		min:         make([]float64, n),
+		max:         make([]float64, n),
+		percentiles: p,
+	}
+	for i := range rb.min {
+		rb.min[i] = math.Inf(1)
+		rb.max[i] = math.Inf(-1)
+	}
+	return rb
+}
+
+// passes returns the number of times the pixels are scanned, 2 with percentiles.
+func (rb *rangeBounds) passes() int {
+	if rb.percentiles.Enabled() {
+		return 2
+	}
+	return 1
+}
+
+// next starts the second pass: the following scans count the pixels in histograms spanning the absolute bounds.
+func (rb *rangeBounds) next() {
+	rb.histograms = make([]*Histogram, len(rb.min))
+	for ci := range rb.histograms {
+		if rb.min[ci] < rb.max[ci] {
+			rb.histograms[ci] = NewHistogram(rb.min[ci], rb.max[ci], histogramBins)
+		}
+	}
+}
+
+// scan accumulates the pixels of the given image for which the optional include func returns true.
+func (rb *rangeBounds) scan(m image.Image, include func(x, y int) bool) {
+	d := m.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			if include != nil && !include(x, y) {
+				continue
+			}
+			rgb, _ := pixel(m, x, y)
+			rgb = rb.space.from(rgb)
+			if rb.histograms != nil {
+				for ci, c64 := range rgb {
+					if h := rb.histograms[ci]; h != nil {
+						h.Add(c64)
+					}
+				}
+				continue
+			}
+
+			if rb.gray && (rgb[c1] != rgb[c2] || rgb[c2] != rgb[c3]) {
+				rb.gray = false
+			}
+			for ci, c64 := range rgb {
+				rb.min[ci] = math.Min(rb.min[ci], c64)
+				rb.max[ci] = math.Max(rb.max[ci], c64)
+			}
+		}
+		if rb.progress != nil {
+			rb.progress()
+		}
+	}
+}
+
+// bounds returns the range bounds of each channel, from the percentiles if enabled.
+func (rb *rangeBounds) bounds() (min, max []float64) {
+	for ci := range rb.min {
+		if rb.min[ci] > rb.max[ci] { // Nothing scanned (e.g. empty mask)
+			rb.min[ci], rb.max[ci] = 0, 0
+		}
+	}
+	if rb.histograms == nil {
+		return rb.min, rb.max
+	}
+
+	min = make([]float64, len(rb.min))
+	max = make([]float64, len(rb.max))
+	for ci, h := range rb.histograms {
+		if h == nil {
+			min[ci], max[ci] = rb.min[ci], rb.max[ci]
+			continue
+		}
+		min[ci] = h.Percentile(rb.percentiles.Low)
+		max[ci] = h.Percentile(rb.percentiles.High)
+	}
+	return min, max
+}
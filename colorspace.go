@@ -0,0 +1,60 @@
+package bilateral
+
+import colorful "github.com/lucasb-eyer/go-colorful"
+
+// ColorSpace defines the colour space of the grid's range axes.
+// The colors are filtered in this space and converted back to RGB when sliced.
+type ColorSpace int
+
+const (
+	// RGB filters the red, green and blue channels (default).
+	RGB ColorSpace = iota
+	// YCbCr filters the luma and the chroma (ITU-R BT.601 full range), the chroma being offset to [0, 1].
+	YCbCr
+	// Lab filters the perceptual CIE L*a*b* channels (D65 white point), a* and b* being offset by 0.5.
+	// Saturated colors may lie out of [0, 1].
+	Lab
+)
+
+// String implements fmt.Stringer interface.
+func (cs ColorSpace) String() string {
+	switch cs {
+	case RGB:
+		return "rgb"
+	case YCbCr:
+		return "ycbcr"
+	case Lab:
+		return "lab"
+	default:
+		return "unknown"
+	}
+}
+
+// from converts the given RGB channels to the colour space.
+func (cs ColorSpace) from(rgb [3]float64) [3]float64 {
+	switch cs {
+	case YCbCr:
+		y := 0.299*rgb[c1] + 0.587*rgb[c2] + 0.114*rgb[c3]
+		return [3]float64{y, (rgb[c3]-y)/1.772 + 0.5, (rgb[c1]-y)/1.402 + 0.5}
+	case Lab:
+		l, a, b := colorful.Color{R: rgb[c1], G: rgb[c2], B: rgb[c3]}.Lab()
+		return [3]float64{l, a + 0.5, b + 0.5}
+	default:
+		return rgb
+	}
+}
+
+// to converts the given channels of the colour space back to RGB.
+func (cs ColorSpace) to(v [3]float64) [3]float64 {
+	switch cs {
+	case YCbCr:
+		r := v[0] + 1.402*(v[2]-0.5)
+		b := v[0] + 1.772*(v[1]-0.5)
+		return [3]float64{r, (v[0] - 0.299*r - 0.114*b) / 0.587, b}
+	case Lab:
+		c := colorful.Lab(v[0], v[1]-0.5, v[2]-0.5)
+		return [3]float64{c.R, c.G, c.B}
+	default:
+		return v
+	}
+}
@@ -8,15 +8,15 @@ import (
 	"time"
 
 	"github.com/mdouchement/bilateral"
-	"github.com/mdouchement/bilateral/luminance"
+	_ "github.com/mdouchement/bilateral/luminance"
 )
 
 var (
 	entries = []map[string]string{
-		{"type": "rgb", "name": "greekdome-gray", "in": "./greekdome-gray.jpeg", "out": "./greekdome-gray-filtered.jpeg"},
-		{"type": "rgb", "name": "greekdome-rgb", "in": "./greekdome.jpeg", "out": "./greekdome-filtered.jpeg"},
-		{"type": "lum", "name": "greekdome-gray-lum", "in": "./greekdome-gray.jpeg", "out": "./greekdome-gray-filtered-lum.jpeg"},
-		{"type": "lum", "name": "greekdome-rgb-lum", "in": "./greekdome.jpeg", "out": "./greekdome-filtered-lum.jpeg"},
+		{"filter": "bilateral", "name": "greekdome-gray", "in": "./greekdome-gray.jpeg", "out": "./greekdome-gray-filtered.jpeg"},
+		{"filter": "bilateral", "name": "greekdome-rgb", "in": "./greekdome.jpeg", "out": "./greekdome-filtered.jpeg"},
+		{"filter": "luminance", "name": "greekdome-gray-lum", "in": "./greekdome-gray.jpeg", "out": "./greekdome-gray-filtered-lum.jpeg"},
+		{"filter": "luminance", "name": "greekdome-rgb-lum", "in": "./greekdome.jpeg", "out": "./greekdome-filtered-lum.jpeg"},
 	}
 )
 
@@ -31,17 +31,11 @@ func main() {
 
 		fmt.Println(entry["name"], " bounds:", m.Bounds().Dx(), m.Bounds().Dy())
 
-		var m2 image.Image
 		start := time.Now()
-		if entry["type"] == "lum" {
-			fbl := luminance.Auto(m)
-			fbl.Execute()
-			m2 = fbl.ResultImage()
-		} else {
-			fbl := bilateral.Auto(m)
-			fbl.Execute()
-			m2 = fbl.ResultImage()
-		}
+		fbl, err := bilateral.NewFilter(entry["filter"], m, 0, 0) // Automatic sigma values
+		check(err)
+		check(fbl.Execute())
+		m2 := fbl.ResultImage()
 		fmt.Printf("%s takes %v\n", entry["name"], time.Now().Sub(start))
 
 		fo, err := os.Create(entry["out"])
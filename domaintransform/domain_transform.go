@@ -0,0 +1,190 @@
+package domaintransform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// Default number of horizontal and vertical passes, after Gastal and Oliveira.
+const defaultIterations = 3
+
+var (
+	// ErrGuideBounds is returned when the guide does not cover the filtered image.
+	ErrGuideBounds = errors.New("domaintransform: guide does not cover the image bounds")
+	// ErrSigma is returned when a sigma is not positive and finite.
+	// It wraps bilateral.ErrInvalidOption.
+	ErrSigma = fmt.Errorf("%w: domain transform sigmas must be positive and finite", bilateral.ErrInvalidOption)
+)
+
+// Mode defines how the transformed domain is filtered.
+type Mode int
+
+const (
+	// Recursive runs a first order recursive filter along the transformed lines (default).
+	Recursive Mode = iota
+	// NormalizedConvolution runs a box filter along the transformed lines.
+	// It is slightly slower and sharper than Recursive.
+	NormalizedConvolution
+)
+
+// A Filter is an edge-aware domain transform filter (Gastal and Oliveira "Domain Transform for Edge-Aware Image and Video Processing").
+// Each image line is warped so that the distances between pixels grow with their color differences,
+// then filtered by a 1-D kernel of SigmaSpace, alternately along the rows and the columns.
+// It approximates a bilateral filter in O(N) regardless of the sigmas.
+type Filter struct {
+	Image      image.Image
+	SigmaRange float64
+	SigmaSpace float64
+	// Guide, when set, is the image whose edges are preserved, Image by default.
+	Guide image.Image
+	// Mode defines how the transformed domain is filtered, Recursive by default.
+	Mode Mode
+	// Iterations is the number of horizontal and vertical passes, 3 when not positive.
+	Iterations int
+	result     *bilateral.FloatImage
+}
+
+var _ bilateral.Filter = (*Filter)(nil)
+
+func init() {
+	bilateral.Register("domaintransform", func(m image.Image, sigmaSpace, sigmaRange float64) bilateral.Filter {
+		if sigmaSpace <= 0 || sigmaRange <= 0 {
+			return Auto(m)
+		}
+		return New(m, sigmaSpace, sigmaRange)
+	})
+}
+
+// Auto instanciates a new Filter with sigma values estimated from the image noise.
+func Auto(m image.Image) *Filter {
+	sigmas := bilateral.EstimateSigmas(m)
+	return New(m, sigmas.Space, sigmas.Range)
+}
+
+// New instanciates a new Filter.
+func New(m image.Image, sigmaSpace, sigmaRange float64) *Filter {
+	return &Filter{
+		Image:      m,
+		SigmaRange: sigmaRange,
+		SigmaSpace: sigmaSpace,
+		Iterations: defaultIterations,
+	}
+}
+
+// Execute runs the domain transform filter.
+func (f *Filter) Execute() error {
+	if !positive(f.SigmaSpace) || !positive(f.SigmaRange) {
+		return ErrSigma
+	}
+
+	m := bilateral.ToFloatImage(f.Image)
+	d := m.Bounds()
+
+	guide := m
+	if f.Guide != nil {
+		if !d.In(f.Guide.Bounds()) {
+			return ErrGuideBounds
+		}
+		guide = bilateral.ToFloatImage(f.Guide)
+	}
+
+	t := newTransform(planes(guide, d), d.Dx(), d.Dy(), f.SigmaSpace/f.SigmaRange)
+	p := planes(m, d)
+	colors := p[:3]
+
+	iterations := f.Iterations
+	if iterations <= 0 {
+		iterations = defaultIterations
+	}
+	for i := 0; i < iterations; i++ {
+		// Sigma of the i-th pass, so that the passes add up to SigmaSpace
+		sigma := f.SigmaSpace * math.Sqrt(3) * math.Pow(2, float64(iterations-i-1)) / math.Sqrt(math.Pow(4, float64(iterations))-1)
+
+		switch f.Mode {
+		case NormalizedConvolution:
+			t.normalizedConvolution(colors, sigma)
+		default:
+			t.recursive(colors, sigma)
+		}
+	}
+
+	f.result = bilateral.NewFloatImage(d)
+	for y := 0; y < d.Dy(); y++ {
+		for x := 0; x < d.Dx(); x++ {
+			i := y*d.Dx() + x
+			f.result.SetFloat(d.Min.X+x, d.Min.Y+y, p[0][i], p[1][i], p[2][i], p[3][i])
+		}
+	}
+	return nil
+}
+
+// ColorModel returns the Image's color model.
+func (f *Filter) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// Bounds implements image.Image interface.
+func (f *Filter) Bounds() image.Rectangle {
+	return f.Image.Bounds()
+}
+
+// At returns the filtered color at the given coordinates.
+func (f *Filter) At(x, y int) color.Color {
+	return util.RGBA8(f.result.FloatAt(x, y))
+}
+
+// ResultImage returns the filtered image.
+func (f *Filter) ResultImage() image.Image {
+	d := f.Image.Bounds()
+	dst := image.NewRGBA(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			dst.Set(x, y, f.At(x, y))
+		}
+	}
+	return dst
+}
+
+// ResultFloat returns the filtered image without quantization.
+func (f *Filter) ResultFloat() *bilateral.FloatImage {
+	return f.result
+}
+
+// String implements fmt.Stringer interface.
+func (m Mode) String() string {
+	switch m {
+	case Recursive:
+		return "recursive"
+	case NormalizedConvolution:
+		return "normalized convolution"
+	default:
+		return "unknown"
+	}
+}
+
+// planes returns the red, green, blue and alpha planes of m within d.
+func planes(m *bilateral.FloatImage, d image.Rectangle) [][]float64 {
+	w, h := d.Dx(), d.Dy()
+	p := make([][]float64, 4)
+	for c := range p {
+		p[c] = make([]float64, w*h)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			p[0][i], p[1][i], p[2][i], p[3][i] = m.FloatAt(d.Min.X+x, d.Min.Y+y)
+		}
+	}
+	return p
+}
+
+// positive returns true if v is positive and finite.
+func positive(v float64) bool {
+	return v > 0 && !math.IsInf(v, 1)
+}
@@ -0,0 +1,135 @@
+package domaintransform_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/domaintransform"
+)
+
+// step returns an image whose left half is dark and right half is bright, covered by a fine texture.
+func step(texture uint8) image.Image {
+	m := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := uint8(40)
+			if x >= 16 {
+				v = 200
+			}
+			if (x+y)%2 == 0 {
+				v += texture
+			}
+			m.Set(x, y, color.RGBA{R: v / 4, G: v, B: v / 2, A: 255})
+		}
+	}
+	return m
+}
+
+func TestFilterModes(t *testing.T) {
+	results := map[domaintransform.Mode]*bilateral.FloatImage{}
+	for _, mode := range []domaintransform.Mode{domaintransform.Recursive, domaintransform.NormalizedConvolution} {
+		filter := domaintransform.New(step(12), 8, 0.2)
+		filter.Mode = mode
+		if err := filter.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", mode, nil, err)
+		}
+		results[mode] = filter.ResultFloat()
+
+		// The texture is smoothed
+		_, a, _, _ := results[mode].FloatAt(6, 8)
+		_, b, _, _ := results[mode].FloatAt(7, 8)
+		if d := math.Abs(a - b); d > 0.01 {
+			t.Errorf("%s: expected: %v, actual: %v", mode.String()+" texture", "< 0.01", d)
+		}
+
+		// The edge is kept
+		_, a, _, _ = results[mode].FloatAt(13, 8)
+		_, b, _, _ = results[mode].FloatAt(18, 8)
+		if e := b - a; e < 0.5 {
+			t.Errorf("%s: expected: %v, actual: %v", mode.String()+" edge", "> 0.5", e)
+		}
+	}
+
+	// Texture's local mean next to the edge, in excess of the dark half's mean
+	leak := func(m *bilateral.FloatImage) float64 {
+		_, a, _, _ := m.FloatAt(15, 8)
+		_, b, _, _ := m.FloatAt(15, 9)
+		return (a+b)/2 - 46.0/255
+	}
+
+	// The recursive filter's exponential tail slightly leaks across the edge,
+	// whereas the box filter of the normalized convolution has a compact support
+	if l := leak(results[domaintransform.Recursive]); l < 1e-6 || l > 1e-3 {
+		t.Errorf("%s: expected: %v, actual: %v", "Recursive leak", "in [1e-6, 1e-3]", l)
+	}
+	if l := leak(results[domaintransform.NormalizedConvolution]); math.Abs(l) > 1e-6 {
+		t.Errorf("%s: expected: %v, actual: %v", "NormalizedConvolution leak", 0, l)
+	}
+}
+
+func TestFilterConstant(t *testing.T) {
+	mi := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range mi.Pix {
+		mi.Pix[i] = 128
+	}
+
+	for _, mode := range []domaintransform.Mode{domaintransform.Recursive, domaintransform.NormalizedConvolution} {
+		filter := domaintransform.New(mi, 4, 0.1)
+		filter.Mode = mode
+		if err := filter.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", mode, nil, err)
+		}
+		if r, _, _, _ := filter.ResultFloat().FloatAt(3, 3); math.Abs(r-128.0/255) > 1e-9 {
+			t.Errorf("%s: expected: %v, actual: %v", mode, 128.0/255, r)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	for name, filter := range map[string]*domaintransform.Filter{
+		"Zero sigma space":     domaintransform.New(step(0), 0, 0.1),
+		"NaN sigma space":      domaintransform.New(step(0), math.NaN(), 0.1),
+		"NaN sigma range":      domaintransform.New(step(0), 4, math.NaN()),
+		"Infinite sigma range": domaintransform.New(step(0), 4, math.Inf(1)),
+	} {
+		if err := filter.Execute(); err != domaintransform.ErrSigma {
+			t.Errorf("%s: expected: %v, actual: %v", name, domaintransform.ErrSigma, err)
+		}
+	}
+	if err := domaintransform.New(step(0), math.NaN(), 0.1).Execute(); !errors.Is(err, bilateral.ErrInvalidOption) {
+		t.Errorf("%s: expected: %v, actual: %v", "Invalid option", bilateral.ErrInvalidOption, err)
+	}
+
+	filter := domaintransform.New(step(0), 4, 0.1)
+	filter.Guide = image.NewGray(image.Rect(0, 0, 4, 4))
+	if err := filter.Execute(); err != domaintransform.ErrGuideBounds {
+		t.Errorf("%s: expected: %v, actual: %v", "Guide", domaintransform.ErrGuideBounds, err)
+	}
+}
+
+func TestAuto(t *testing.T) {
+	filter := domaintransform.Auto(step(12))
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	if !filter.Bounds().Eq(filter.ResultImage().Bounds()) {
+		t.Errorf("%s: expected: %v, actual: %v", "Bounds", filter.Bounds(), filter.ResultImage().Bounds())
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	filter, err := bilateral.NewFilter("domaintransform", step(12), 3, 0.1)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewFilter", nil, err)
+	}
+	if _, ok := filter.(*domaintransform.Filter); !ok {
+		t.Errorf("%s: expected: %T, actual: %T", "NewFilter", &domaintransform.Filter{}, filter)
+	}
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+}
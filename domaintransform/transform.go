@@ -0,0 +1,116 @@
+package domaintransform
+
+import "math"
+
+// A transform holds the distances between neighbour pixels in the transformed domain.
+type transform struct {
+	w, h int
+	// dx[i] is the distance between the pixel i and its left neighbour.
+	dx []float64
+	// dy[i] is the distance between the pixel i and its top neighbour.
+	dy []float64
+}
+
+// A line locates a row or a column in the planes.
+type line struct {
+	start, stride, n int
+	distances        []float64
+}
+
+// newTransform computes the distances 1 + ratio * sum(|color differences|) from the guide's planes.
+func newTransform(guide [][]float64, w, h int, ratio float64) *transform {
+	t := &transform{
+		w:  w,
+		h:  h,
+		dx: make([]float64, w*h),
+		dy: make([]float64, w*h),
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			var sx, sy float64
+			for c := 0; c < 3; c++ { // red, green, blue
+				if x > 0 {
+					sx += math.Abs(guide[c][i] - guide[c][i-1])
+				}
+				if y > 0 {
+					sy += math.Abs(guide[c][i] - guide[c][i-w])
+				}
+			}
+			t.dx[i] = 1 + ratio*sx
+			t.dy[i] = 1 + ratio*sy
+		}
+	}
+	return t
+}
+
+// lines returns the rows then the columns of the planes.
+func (t *transform) lines() (rows, columns []line) {
+	for y := 0; y < t.h; y++ {
+		rows = append(rows, line{start: y * t.w, stride: 1, n: t.w, distances: t.dx})
+	}
+	for x := 0; x < t.w; x++ {
+		columns = append(columns, line{start: x, stride: t.w, n: t.h, distances: t.dy})
+	}
+	return
+}
+
+// recursive runs a horizontal and a vertical recursive pass of the given sigma on the planes.
+func (t *transform) recursive(planes [][]float64, sigma float64) {
+	a := math.Exp(-math.Sqrt2 / sigma)
+	rows, columns := t.lines()
+	for _, lines := range [][]line{rows, columns} {
+		for _, l := range lines {
+			// Feedback coefficients a^distance
+			v := make([]float64, l.n)
+			for k := 1; k < l.n; k++ {
+				v[k] = math.Pow(a, l.distances[l.start+k*l.stride])
+			}
+
+			for _, p := range planes {
+				for k := 1; k < l.n; k++ { // left to right
+					i := l.start + k*l.stride
+					p[i] += v[k] * (p[i-l.stride] - p[i])
+				}
+				for k := l.n - 2; k >= 0; k-- { // right to left
+					i := l.start + k*l.stride
+					p[i] += v[k+1] * (p[i+l.stride] - p[i])
+				}
+			}
+		}
+	}
+}
+
+// normalizedConvolution runs a horizontal and a vertical box pass of the given sigma on the planes.
+func (t *transform) normalizedConvolution(planes [][]float64, sigma float64) {
+	radius := sigma * math.Sqrt(3)
+	rows, columns := t.lines()
+	for _, lines := range [][]line{rows, columns} {
+		for _, l := range lines {
+			// Transformed coordinates
+			ct := make([]float64, l.n)
+			for k := 1; k < l.n; k++ {
+				ct[k] = ct[k-1] + l.distances[l.start+k*l.stride]
+			}
+
+			sums := make([]float64, l.n+1)
+			for _, p := range planes {
+				for k := 0; k < l.n; k++ {
+					sums[k+1] = sums[k] + p[l.start+k*l.stride]
+				}
+
+				// Box bounds [lower, upper) move forward with k
+				lower, upper := 0, 0
+				for k := 0; k < l.n; k++ {
+					for ct[lower] < ct[k]-radius {
+						lower++
+					}
+					for upper < l.n && ct[upper] <= ct[k]+radius {
+						upper++
+					}
+					p[l.start+k*l.stride] = (sums[upper] - sums[lower]) / float64(upper-lower)
+				}
+			}
+		}
+	}
+}
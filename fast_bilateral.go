@@ -1,12 +1,13 @@
 package bilateral
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"math"
-	"math/big"
 	"sync"
 
+	"github.com/mdouchement/bilateral/internal/util"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -28,10 +29,64 @@ type FastBilateral struct {
 	Image      image.Image
 	SigmaRange float64
 	SigmaSpace float64
+	// Guide, when set, is the image whose colors define the range coordinates (joint bilateral filtering).
+	// The filtered colors are still taken from Image. It must cover the Image's bounds.
+	Guide image.Image
+	// Mask, when set, restricts the filtering to the pixels where its alpha is not zero.
+	// Only those pixels contribute to the grid, the others are copied through.
+	// Partial alphas blend the filtered and the original colors.
+	Mask image.Image
+	// Confidence, when set, scales the contribution of each pixel to the grid by its gray level clamped to [0, 1]
+	// (normalized convolution). Pixels with a zero confidence are filled in from their neighbours
+	// with similar range coordinates, so a Guide should be used when their colors are invalid.
+	Confidence image.Image
+	// SigmaRanges, when set, holds the range sigma of each colour channel (red, green, blue).
+	// Zero entries fall back to SigmaRange. When every pixel is gray, the single gray channel uses the first (red) entry.
+	SigmaRanges []float64
+	// SigmaSpaceY, when positive, is the spatial sigma along the vertical axis
+	// and SigmaSpace is only used along the horizontal axis.
+	SigmaSpaceY float64
+	// Rotation is the angle in radians between the image axes and the spatial sigmas' axes.
+	Rotation float64
+	// Kernels holds the blur kernel of each grid axis (x, y, then the colour depths).
+	// Missing or zero kernels fall back to BinomialKernel.
+	Kernels []Kernel
+	// RangeKernel, when set, is the kernel of the colour depths missing from Kernels,
+	// e.g. a robust TukeyKernel, LorentzianKernel or HuberKernel instead of the implicit Gaussian.
+	RangeKernel Kernel
+	// Splatting defines how the pixels are accumulated into the grid, Nearest by default.
+	Splatting Splatting
+	// Interpolation defines how the grid is sliced, Linear by default.
+	Interpolation Interpolation
+	// ColorSpace defines the colour space of the range axes, RGB by default.
+	// SigmaRange and SigmaRanges are expressed in this space's channels.
+	ColorSpace ColorSpace
+	// Workers, when greater than 1, is the number of goroutines slicing the grid
+	// in ResultImage, ResultFloat and ResultMap.
+	Workers int
+	// Output defines the image type returned by ResultImage, OutputRGBA by default.
+	Output Output
+	// OnProgress, when set, is called with the progress of Execute's phases and of the slicing.
+	OnProgress ProgressFunc
+	// Percentiles, when enabled, computes the range bounds from the given percentiles
+	// instead of the absolute min and max. Outliers are clamped into the edge bins.
+	Percentiles Percentiles
+	// MaxMemory, when positive, caps the memory in bytes allocated by the grids (see Footprint).
+	MaxMemory int64
+	// Coarsen makes Execute coarsen the grid sampling until it fits MaxMemory,
+	// instead of returning ErrMemoryLimit.
+	Coarsen    bool
 	dimension  int
 	minmaxOnce sync.Once
-	min        []float64
-	max        []float64
+	// Nearest contributing pixel of each pixel, computed on the first empty slice.
+	nearestOnce sync.Once
+	nearestPix  []int
+	min         []float64
+	max         []float64
+	// Origin of the spatial sampling lattice, defaults to the image bounds' min.
+	origin *image.Point
+	// Grid cells skipped between the lattice origin and the image bounds' min.
+	shift [2]float64
 	// Grid size:
 	// 0 -> smallWidth
 	// 1 -> smallHeight
@@ -71,10 +126,34 @@ func New(img image.Image, sigmaSpace, sigmaRange float64) *FastBilateral {
 }
 
 // Execute runs the bilateral filter.
-func (f *FastBilateral) Execute() {
+// It returns an error wrapping ErrInvalidOption if the sigma values are not positive and finite
+// or if a kernel is invalid, or wrapping ErrMemoryLimit if the grids do not fit MaxMemory.
+func (f *FastBilateral) Execute() error {
+	if !positive(f.SigmaSpace) {
+		return fmt.Errorf("%w: sigma space must be positive and finite, got %v", ErrInvalidOption, f.SigmaSpace)
+	}
+	if !f.auto && !positive(f.SigmaRange) {
+		return fmt.Errorf("%w: sigma range must be positive and finite, got %v", ErrInvalidOption, f.SigmaRange)
+	}
+	if math.IsInf(f.SigmaSpaceY, 1) {
+		return fmt.Errorf("%w: sigma space y must be finite", ErrInvalidOption)
+	}
+	for c, sigma := range f.SigmaRanges {
+		if math.IsInf(sigma, 1) {
+			return fmt.Errorf("%w: sigma range of channel %d must be finite", ErrInvalidOption, c)
+		}
+	}
+	if err := validateKernels(f.Kernels, f.RangeKernel); err != nil {
+		return err
+	}
+
 	f.minmaxOnce.Do(f.minmax)
+	if err := f.fit(); err != nil {
+		return err
+	}
 	f.downsampling()
 	f.convolution()
+	return nil
 }
 
 // ColorModel returns the Image's color model.
@@ -89,67 +168,134 @@ func (f *FastBilateral) Bounds() image.Rectangle {
 
 // At computes the interpolation and returns the filtered color at the given coordinates.
 func (f *FastBilateral) At(x, y int) color.Color {
-	pixel := f.Image.At(x, y)
-	r, g, b, a := pixel.RGBA()
-	rgb := []float64{fcolor(r), fcolor(g), fcolor(b)}
+	if f.coverage(x, y) == 0 {
+		return color.RGBAModel.Convert(f.Image.At(x, y)) // Copied through
+	}
+
+	rgb, a := f.filtered(x, y)
+	return util.RGBA8(rgb[c1], rgb[c2], rgb[c3], a)
+}
+
+// filtered computes the interpolation and returns the filtered colors and the alpha at the given coordinates.
+func (f *FastBilateral) filtered(x, y int) ([3]float64, float64) {
+	coverage := f.coverage(x, y)
+	if coverage == 0 {
+		return pixel(f.Image, x, y) // Copied through
+	}
+
+	rgb, a := f.pixel(f.Image, x, y)
+	key := rgb
+	if f.Guide != nil {
+		key, _ = f.pixel(f.Guide, x, y)
+	}
 
 	offset := make([]float64, f.dimension)
 	// Grid coords
-	offset[0] = float64(x)/f.SigmaSpace + paddingS // Grid width
-	offset[1] = float64(y)/f.SigmaSpace + paddingS // Grid height
+	gx, gy := f.spaceCoord(x, y)
+	offset[0] = gx + paddingS // Grid width
+	offset[1] = gy + paddingS // Grid height
 	for z := 0; z < f.dimension-2; z++ {
-		offset[2+z] = (rgb[z]-f.min[z])/f.SigmaRange + paddingR // Grid color
+		offset[2+z] = f.rangeCoord(z, key[z]) + paddingR // Grid color
 	}
 
-	c := f.nLinearInterpolation(offset...)
+	c := f.slice(offset...)
+	if c.threshold <= 0 {
+		// No pixel contributes around (x, y), e.g. a hole of zero confidence wider than the blur
+		return f.fill(x, y, coverage)
+	}
 	c.colors.ScaleVec(1/c.threshold, c.colors) // Normalize
 
 	len := c.colors.Len()
-	channel := func(z int) uint8 {
-		if z < len {
-			return uint8(clamp(0, 255, int(c.colors.AtVec(z)*255)))
+	for z := range rgb {
+		v := c.colors.AtVec(util.Clamp(0, len-1, z)) // Gray grids hold one channel
+		if coverage < 1 {
+			v = coverage*v + (1-coverage)*rgb[z]
 		}
-		return uint8(clamp(0, 255, int(c.colors.AtVec(len-1)*255)))
-	}
-	return color.RGBA{
-		R: channel(c1),
-		G: channel(c2),
-		B: channel(c3),
-		A: uint8(a),
+		rgb[z] = v
 	}
+	return f.ColorSpace.to(rgb), a
 }
 
-// ResultImage computes the interpolation and returns the filtered image.
+// ResultImage computes the interpolation and returns the filtered image, of the Output type.
 func (f *FastBilateral) ResultImage() image.Image {
-	d := f.Image.Bounds()
-	dst := image.NewRGBA(d)
-	for x := 0; x < d.Dx(); x++ {
-		for y := 0; y < d.Dy(); y++ {
+	return f.resultImage(f.Image.Bounds())
+}
+
+// resultImage computes the interpolation and returns the filtered pixels within r, of the Output type.
+func (f *FastBilateral) resultImage(r image.Rectangle) image.Image {
+	switch f.Output {
+	case OutputRGBA64:
+		return f.result64(r)
+	case OutputFloat:
+		return f.resultFloat(r)
+	default:
+		return f.result(r)
+	}
+}
+
+// result computes the interpolation and returns the filtered pixels within r.
+func (f *FastBilateral) result(r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(r)
+	f.rows(r, func(y int) {
+		for x := r.Min.X; x < r.Max.X; x++ {
 			dst.Set(x, y, f.At(x, y))
 		}
-	}
+	})
 	return dst
 }
 
-func (f *FastBilateral) minmax() {
-	gray := true
+// ResultFloat computes the interpolation and returns the filtered image without quantization.
+func (f *FastBilateral) ResultFloat() *FloatImage {
+	return f.resultFloat(f.Image.Bounds())
+}
+
+// resultFloat computes the interpolation and returns the filtered pixels within r without quantization.
+func (f *FastBilateral) resultFloat(r image.Rectangle) *FloatImage {
+	dst := NewFloatImage(r)
+	f.rows(r, func(y int) {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			rgb, a := f.filtered(x, y)
+			dst.SetFloat(x, y, rgb[c1], rgb[c2], rgb[c3], a)
+		}
+	})
+	return dst
+}
+
+// ResultMap computes the interpolation and returns the filtered first channel, for single-channel maps.
+func (f *FastBilateral) ResultMap() *FloatMap {
 	d := f.Image.Bounds()
-	for y := 0; y < d.Dy(); y++ {
-		for x := 0; x < d.Dx(); x++ {
-			pixel := f.Image.At(x, y)
-			r, g, b, _ := pixel.RGBA()
-			if gray && (r != g || g != b) {
-				gray = false
-			}
-			for ci, c := range []uint32{r, g, b} {
-				c64 := fcolor(c)
-				f.min[ci] = math.Min(f.min[ci], c64)
-				f.max[ci] = math.Max(f.max[ci], c64)
-			}
+	dst := NewFloatMap(d)
+	f.rows(d, func(y int) {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			rgb, _ := f.filtered(x, y)
+			dst.SetFloat(x, y, float32(rgb[c1]))
 		}
+	})
+	return dst
+}
+
+func (f *FastBilateral) minmax() {
+	b := newRangeBounds(len(f.min), f.Percentiles)
+	b.space = f.ColorSpace
+	b.progress = f.OnProgress.Counter(PhaseMinmax, b.passes()*f.reference().Bounds().Dy())
+	for pass := 0; pass < b.passes(); pass++ {
+		if pass > 0 {
+			b.next()
+		}
+		b.scan(f.reference(), func(x, y int) bool {
+			return f.weight(x, y) > 0
+		})
 	}
+	f.setBounds(b)
+}
 
-	if gray {
+// setBounds applies the scanned range bounds and computes the automatic sigma values.
+func (f *FastBilateral) setBounds(b *rangeBounds) {
+	min, max := b.bounds()
+	copy(f.min, min)
+	copy(f.max, max)
+
+	if b.gray {
 		// Go to gray scale to spped up the algo
 		f.dimension = 3 // x, y, z
 		f.size = f.size[0:f.dimension]
@@ -164,99 +310,212 @@ func (f *FastBilateral) minmax() {
 			min = math.Min(min, f.min[n])
 			max = math.Max(max, f.max[n])
 		}
-		f.SigmaRange = (max - min) * 0.1
+		f.SigmaRange = math.Max((max-min)*0.1, minSigmaRange) // Floored for flat images
 	}
 
-	f.size[0] = int(float64(d.Dx()-1)/f.SigmaSpace) + 1 + 2*paddingS
-	f.size[1] = int(float64(d.Dy()-1)/f.SigmaSpace) + 1 + 2*paddingS
+	// fmt.Println("ssp:", f.SigmaSpace, " - sra:", f.SigmaRange)
+	// fmt.Println("min:", f.min, "- max:", f.max)
+}
+
+// resize computes the grid size from the sigma values.
+func (f *FastBilateral) resize() {
+	d := f.Image.Bounds()
+	corners := [4]image.Point{d.Min, {d.Max.X - 1, d.Min.Y}, {d.Min.X, d.Max.Y - 1}, d.Max.Sub(image.Pt(1, 1))}
+	min := [2]float64{math.Inf(1), math.Inf(1)}
+	max := [2]float64{math.Inf(-1), math.Inf(-1)}
+	for _, p := range corners {
+		u, v := f.project(p.X, p.Y)
+		min[0], max[0] = math.Min(min[0], u), math.Max(max[0], u)
+		min[1], max[1] = math.Min(min[1], v), math.Max(max[1], v)
+	}
+	f.shift[0] = math.Floor(min[0])
+	f.shift[1] = math.Floor(min[1])
+
+	f.size[0] = int(max[0]-f.shift[0]) + 1 + 2*paddingS
+	f.size[1] = int(max[1]-f.shift[1]) + 1 + 2*paddingS
 	for c := 0; c < f.dimension-2; c++ {
-		f.size[2+c] = int((f.max[c]-f.min[c])/f.SigmaRange) + 1 + 2*paddingR
+		f.size[2+c] = int((f.max[c]-f.min[c])/f.sigmaRange(c)) + 1 + 2*paddingR
 	}
 
-	// fmt.Println("ssp:", f.SigmaSpace, " - sra:", f.SigmaRange)
-	// fmt.Println("min:", f.min, "- max:", f.max)
 	// fmt.Println("size:", mul(f.size...), f.size)
 }
 
+// lattice returns the origin of the spatial sampling lattice.
+func (f *FastBilateral) lattice() image.Point {
+	if f.origin != nil {
+		return *f.origin
+	}
+	return f.Image.Bounds().Min
+}
+
+// sigmaSpaceY returns the spatial sigma along the vertical axis.
+func (f *FastBilateral) sigmaSpaceY() float64 {
+	if f.SigmaSpaceY > 0 {
+		return f.SigmaSpaceY
+	}
+	return f.SigmaSpace
+}
+
+// project returns the position of the pixel (x, y) on the spatial lattice, in sigma units.
+func (f *FastBilateral) project(x, y int) (float64, float64) {
+	o := f.lattice()
+	dx, dy := float64(x-o.X), float64(y-o.Y)
+	if f.Rotation != 0 {
+		sin, cos := math.Sincos(f.Rotation)
+		dx, dy = dx*cos+dy*sin, dy*cos-dx*sin
+	}
+	return dx / f.SigmaSpace, dy / f.sigmaSpaceY()
+}
+
+// spaceCoord returns the unpadded grid coordinates of the pixel (x, y).
+func (f *FastBilateral) spaceCoord(x, y int) (float64, float64) {
+	u, v := f.project(x, y)
+	return u - f.shift[0], v - f.shift[1]
+}
+
+// rangeCoord returns the unpadded grid coordinate of the value v of the channel c.
+// Values out of the range bounds are clamped into the edge bins.
+func (f *FastBilateral) rangeCoord(c int, v float64) float64 {
+	return (util.Clampf(f.min[c], f.max[c], v) - f.min[c]) / f.sigmaRange(c)
+}
+
+// kernels returns the blur kernel of each grid axis, RangeKernel filling the colour depths missing from Kernels.
+func (f *FastBilateral) kernels() []Kernel {
+	if len(f.RangeKernel.Weights) == 0 {
+		return f.Kernels
+	}
+
+	kernels := make([]Kernel, f.dimension)
+	copy(kernels, f.Kernels)
+	for axis := 2; axis < f.dimension; axis++ {
+		if len(kernels[axis].Weights) == 0 {
+			kernels[axis] = f.RangeKernel
+		}
+	}
+	return kernels
+}
+
+// fill returns the filtered colors of the nearest contributing pixel, blended by the coverage,
+// for the pixel (x, y) whose grid neighbourhood is empty.
+// The original colors are kept when no pixel contributes to the grid.
+func (f *FastBilateral) fill(x, y int, coverage float64) ([3]float64, float64) {
+	rgb, a := pixel(f.Image, x, y)
+
+	d := f.Image.Bounds()
+	f.nearestOnce.Do(func() {
+		f.nearestPix = util.Nearest(d, func(x, y int) bool {
+			return f.weight(x, y) > 0
+		})
+	})
+	if !(image.Point{x, y}.In(d)) {
+		return rgb, a
+	}
+	i := f.nearestPix[(y-d.Min.Y)*d.Dx()+x-d.Min.X]
+	nx, ny := d.Min.X+i%d.Dx(), d.Min.Y+i/d.Dx()
+	if i < 0 || (nx == x && ny == y) {
+		return rgb, a
+	}
+
+	filled, _ := f.filtered(nx, ny)
+	for z := range rgb {
+		rgb[z] = coverage*filled[z] + (1-coverage)*rgb[z]
+	}
+	return rgb, a
+}
+
+// pixel returns the colors of the pixel (x, y) of the given image in the ColorSpace, and its alpha.
+func (f *FastBilateral) pixel(m image.Image, x, y int) ([3]float64, float64) {
+	rgb, a := pixel(m, x, y)
+	return f.ColorSpace.from(rgb), a
+}
+
+// reference returns the image defining the range coordinates.
+func (f *FastBilateral) reference() image.Image {
+	if f.Guide != nil {
+		return f.Guide
+	}
+	return f.Image
+}
+
+// coverage returns the mask's alpha at (x, y) in [0, 1], 1 without mask.
+func (f *FastBilateral) coverage(x, y int) float64 {
+	return coverage(f.Mask, x, y)
+}
+
+// weight returns the contribution of the pixel (x, y) to the grid, zero out of the mask.
+func (f *FastBilateral) weight(x, y int) float64 {
+	if f.coverage(x, y) == 0 {
+		return 0
+	}
+	return confidence(f.Confidence, x, y)
+}
+
+// channels returns the number of colors accumulated in the grid cells.
+// Joint filtering accumulates the three colors, even when the guide is gray.
+func (f *FastBilateral) channels() int {
+	if f.Guide != nil {
+		return 3
+	}
+	return f.dimension - 2
+}
+
+// sigmaRange returns the range sigma of the channel c.
+func (f *FastBilateral) sigmaRange(c int) float64 {
+	if c < len(f.SigmaRanges) && f.SigmaRanges[c] > 0 {
+		return f.SigmaRanges[c]
+	}
+	return f.SigmaRange
+}
+
 func (f *FastBilateral) downsampling() {
 	d := f.Image.Bounds()
 	offset := make([]int, f.dimension)
+	coords := make([]float64, f.dimension)
 
-	dim := f.dimension - 2
+	dim := f.channels()
 	f.grid = newGrid(f.size, dim)
+	step := f.OnProgress.Counter(PhaseDownsampling, d.Dx())
 
-	for x := 0; x < d.Dx(); x++ {
-		offset[0] = int(1*float64(x)/f.SigmaSpace+0.5) + paddingS
+	for x := d.Min.X; x < d.Max.X; x++ {
+		for y := d.Min.Y; y < d.Max.Y; y++ {
+			w := f.weight(x, y)
+			if w == 0 {
+				continue
+			}
+			gx, gy := f.spaceCoord(x, y)
 
-		for y := 0; y < d.Dy(); y++ {
-			offset[1] = int(1*float64(y)/f.SigmaSpace+0.5) + paddingS
+			rgb, _ := f.pixel(f.Image, x, y)
+			key := rgb
+			if f.Guide != nil {
+				key, _ = f.pixel(f.Guide, x, y)
+			}
 
-			pixel := f.Image.At(x, y)
-			r, g, b, _ := pixel.RGBA()
-			rgb := []float64{fcolor(r), fcolor(g), fcolor(b)}
+			if f.Splatting == Tent {
+				coords[0] = gx + paddingS
+				coords[1] = gy + paddingS
+				for z := 0; z < f.dimension-2; z++ {
+					coords[2+z] = f.rangeCoord(z, key[z]) + paddingR
+				}
+				f.tentSplat(coords, mat.NewVecDense(dim, rgb[0:dim]), w)
+				continue
+			}
 
+			offset[0] = int(gx+0.5) + paddingS
+			offset[1] = int(gy+0.5) + paddingS
 			for z := 0; z < f.dimension-2; z++ {
-				offset[2+z] = int((rgb[z]-f.min[z])/f.SigmaRange+0.5) + paddingR
+				offset[2+z] = int(f.rangeCoord(z, key[z])+0.5) + paddingR
 			}
 
 			v := f.grid.At(offset...)
-			v.colors.AddVec(v.colors, mat.NewVecDense(dim, rgb[0:f.dimension-2]))
-			v.threshold++
+			v.colors.AddScaledVec(v.colors, w, mat.NewVecDense(dim, rgb[0:dim]))
+			v.threshold += w
 		}
+		step()
 	}
 }
 
 func (f *FastBilateral) convolution() {
-	dim := f.dimension - 2
-	buffer := newGrid(f.size, dim)
-
-	var vg *cell
-	var prev *cell
-	var curr *cell
-	var next *cell
-
-	for dim := 0; dim < f.dimension; dim++ { // x, y, and colors depths
-		off := make([]int, f.dimension)
-		off[dim] = 1 // Wanted dimension offset
-
-		for n := 0; n < 2; n++ { // itterations (pass?)
-			f.grid, buffer = buffer, f.grid
-
-			for x := 1; x < f.size[0]-1; x++ {
-				for y := 1; y < f.size[1]-1; y++ {
-
-					for z1 := 1; z1 < f.size[2+c1]-1; z1++ {
-						if f.dimension == 5 {
-							for z2 := 1; z2 < f.size[2+c2]-1; z2++ {
-								for z3 := 1; z3 < f.size[2+c3]-1; z3++ {
-									vg = f.grid.At(x, y, z1, z2, z3)
-									prev = buffer.At(x-off[0], y-off[1], z1-off[2], z2-off[3], z3-off[4])
-									curr = buffer.At(x, y, z1, z2, z3)
-									next = buffer.At(x+off[0], y+off[1], z1+off[2], z2+off[3], z3+off[4])
-
-									// (prev + 2.0 * curr + next) / 4.0
-									vg.Add(prev, next)
-									vg.AddScaled(vg, 2, curr)
-									vg.Scale(0.25, vg)
-								}
-							}
-						} else {
-							vg = f.grid.At(x, y, z1)
-							prev = buffer.At(x-off[0], y-off[1], z1-off[2])
-							curr = buffer.At(x, y, z1)
-							next = buffer.At(x+off[0], y+off[1], z1+off[2])
-
-							// (prev + 2.0 * curr + next) / 4.0
-							vg.Add(prev, next)
-							vg.AddScaled(vg, 2, curr)
-							vg.Scale(0.25, vg)
-						}
-					}
-				}
-			}
-		}
-	}
-	return
+	f.grid = f.grid.convolve(f.kernels(), f.channels(), f.OnProgress.pass)
 }
 
 // Perform linear interpolation.
@@ -291,38 +550,5 @@ func (f *FastBilateral) convolution() {
 // 		ya*xa*za*f.grid.At(xx, yy, zz).colors.At(c1, 0)
 // }
 func (f *FastBilateral) nLinearInterpolation(offset ...float64) *cell {
-	permutations := 1 << uint(f.dimension)
-	index := make([]int, f.dimension)
-	indexx := make([]int, f.dimension)
-	alpha := make([]float64, f.dimension)
-
-	for n, s := range f.size {
-		off := offset[n]
-		size := s - 1
-		index[n] = clamp(0, size, int(off))
-		indexx[n] = clamp(0, size, index[n]+1)
-		alpha[n] = off - float64(index[n])
-	}
-
-	// Interpolation
-	c := &cell{colors: mat.NewVecDense(f.dimension-2, nil)}
-	bitset := big.NewInt(int64(0)) // Use to perform all the interpolation's permutations
-	off := make([]int, f.dimension)
-	var scale float64
-	for i := 0; i < permutations; i++ {
-		bitset.SetUint64(uint64(i))
-		scale = 1.0
-		for n := 0; n < f.dimension; n++ {
-			if bitset.Bit(n) == 1 {
-				off[n] = index[n]
-				scale *= 1.0 - alpha[n]
-			} else {
-				off[n] = indexx[n]
-				scale *= alpha[n]
-			}
-		}
-		c.AddScaled(c, scale, f.grid.At(off...))
-	}
-
-	return c
+	return f.grid.linear(f.channels(), offset...)
 }
@@ -1,8 +1,10 @@
 package bilateral_test
 
 import (
+	"image"
 	"image/color"
 	_ "image/jpeg"
+	"math"
 	"reflect"
 	"testing"
 
@@ -70,3 +72,42 @@ func TestFastBilateralGray(t *testing.T) {
 		}
 	}
 }
+
+func TestFastBilateralAnisotropic(t *testing.T) {
+	// Horizontal stripes
+	mi := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			mi.SetGray(x, y, color.Gray{Y: uint8(100 + 40*(y%2))})
+		}
+	}
+	contrast := func(m image.Image) int {
+		r1, _, _, _ := m.At(16, 16).RGBA()
+		r2, _, _, _ := m.At(16, 17).RGBA()
+		return int(r2>>8) - int(r1>>8)
+	}
+
+	isotropic := bilateral.New(mi, 8, 0.5)
+	isotropic.Execute()
+
+	anisotropic := bilateral.New(mi, 8, 0.5)
+	anisotropic.SigmaSpaceY = 0.2
+	anisotropic.Execute()
+
+	if c := contrast(isotropic.ResultImage()); c > 10 {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Isotropic contrast", 10, c)
+	}
+	if c := contrast(anisotropic.ResultImage()); c < 30 {
+		t.Errorf("%s: expected greater than %d, actual: %d", "Anisotropic contrast", 30, c)
+	}
+
+	rotated := bilateral.New(mi, 8, 0.5)
+	rotated.SigmaSpaceY = 0.2
+	rotated.Rotation = math.Pi / 2
+	rotated.Execute()
+
+	// SigmaSpace is now applied across the stripes
+	if c := contrast(rotated.ResultImage()); c > 10 {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Rotated contrast", 10, c)
+	}
+}
@@ -0,0 +1,105 @@
+package bilateral
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownFilter is returned when no filter is registered under the given name.
+var ErrUnknownFilter = errors.New("bilateral: unknown filter")
+
+type (
+	// A Filter is an edge-preserving filter.
+	// Once executed, the filtered image is read through the image.Image interface or ResultImage.
+	Filter interface {
+		image.Image
+		Execute() error
+		ResultImage() image.Image
+	}
+
+	// A Factory instanciates a Filter of the given image.
+	// The sigma values are picked automatically when any of them is not positive.
+	Factory func(m image.Image, sigmaSpace, sigmaRange float64) Filter
+)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+
+	_ Filter = (*FastBilateral)(nil)
+	_ Filter = (*RollingGuidance)(nil)
+	_ Filter = (*Abstraction)(nil)
+	_ Filter = (*Varying)(nil)
+)
+
+func init() {
+	Register("bilateral", func(m image.Image, sigmaSpace, sigmaRange float64) Filter {
+		if sigmaSpace <= 0 || sigmaRange <= 0 {
+			return Auto(m)
+		}
+		return New(m, sigmaSpace, sigmaRange)
+	})
+	Register("rolling", func(m image.Image, sigmaSpace, sigmaRange float64) Filter {
+		if sigmaSpace <= 0 || sigmaRange <= 0 {
+			s := EstimateSigmas(m)
+			sigmaSpace, sigmaRange = s.Space, s.Range
+		}
+		return NewRollingGuidance(m, sigmaSpace, sigmaRange, 0)
+	})
+	Register("abstraction", func(m image.Image, sigmaSpace, sigmaRange float64) Filter {
+		if sigmaSpace <= 0 || sigmaRange <= 0 {
+			s := EstimateSigmas(m)
+			sigmaSpace, sigmaRange = s.Space, s.Range
+		}
+		return NewAbstraction(m, sigmaSpace, sigmaRange)
+	})
+}
+
+// Register makes a filter available by the given name.
+// Subpackages register their filters when imported, e.g.
+//
+//	import _ "github.com/mdouchement/bilateral/luminance"
+//
+// It panics if the factory is nil or if a filter is already registered under the name.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if factory == nil {
+		panic("bilateral: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("bilateral: Register called twice for filter " + name)
+	}
+	factories[name] = factory
+}
+
+// NewFilter instanciates the filter registered under the given name.
+// The sigma values are picked automatically when any of them is not positive.
+// It returns an error wrapping ErrUnknownFilter if no filter is registered under the name.
+func NewFilter(name string, m image.Image, sigmaSpace, sigmaRange float64) (Filter, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFilter, name)
+	}
+	return factory(m, sigmaSpace, sigmaRange), nil
+}
+
+// Filters returns the sorted names of the registered filters.
+func Filters() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,60 @@
+package bilateral_test
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestNewFilter(t *testing.T) {
+	mi := images["base-gray"]
+	mo := images["base-gray-filtered"]
+
+	filter, err := bilateral.NewFilter("bilateral", mi, 0, 0)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewFilter", nil, err)
+	}
+	filter.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "ResultImage", mo, filter.ResultImage())
+	}
+
+	if _, err := bilateral.NewFilter("unknown", mi, 16, 0.1); !errors.Is(err, bilateral.ErrUnknownFilter) {
+		t.Errorf("%s: expected: %v, actual: %v", "Unknown", bilateral.ErrUnknownFilter, err)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	names := map[string]bool{}
+	for _, name := range bilateral.Filters() {
+		names[name] = true
+	}
+	for _, name := range []string{"bilateral", "rolling", "abstraction"} {
+		if !names[name] {
+			t.Errorf("%s: expected: %v, actual: %v", name, true, names[name])
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	factory := func(m image.Image, sigmaSpace, sigmaRange float64) bilateral.Filter {
+		return bilateral.New(m, sigmaSpace, sigmaRange)
+	}
+	bilateral.Register("test-register", factory)
+
+	filter, err := bilateral.NewFilter("test-register", images["base-gray"], 16, 0.1)
+	if _, ok := filter.(*bilateral.FastBilateral); err != nil || !ok {
+		t.Errorf("%s: expected: %T, actual: %T (%v)", "NewFilter", &bilateral.FastBilateral{}, filter, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: expected: %s, actual: %s", "Register", "panic", "no panic")
+		}
+	}()
+	bilateral.Register("test-register", factory)
+}
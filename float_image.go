@@ -0,0 +1,115 @@
+package bilateral
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// A FloatImage is an in-memory image whose pixels are red, green, blue and alpha float64 channels in [0, 1],
+// alpha-premultiplied like color.RGBA64.
+// It is read and written by the filters without any quantization, so it can be used to chain them.
+type FloatImage struct {
+	// Pix holds the image's pixels, in R, G, B, A order.
+	// The pixel at (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*4].
+	Pix []float64
+	// Stride is the Pix stride between two vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewFloatImage returns a new FloatImage with the given bounds.
+func NewFloatImage(r image.Rectangle) *FloatImage {
+	return &FloatImage{
+		Pix:    make([]float64, 4*r.Dx()*r.Dy()),
+		Stride: 4 * r.Dx(),
+		Rect:   r,
+	}
+}
+
+// ToFloatImage converts the given image into a FloatImage.
+func ToFloatImage(m image.Image) *FloatImage {
+	if fm, ok := m.(*FloatImage); ok {
+		return fm
+	}
+
+	d := m.Bounds()
+	fm := NewFloatImage(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			r, g, b, a := m.At(x, y).RGBA()
+			fm.SetFloat(x, y, util.Color(r), util.Color(g), util.Color(b), util.Color(a))
+		}
+	}
+	return fm
+}
+
+// ColorModel implements image.Image interface.
+func (p *FloatImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+// Bounds implements image.Image interface.
+func (p *FloatImage) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At implements image.Image interface.
+func (p *FloatImage) At(x, y int) color.Color {
+	r, g, b, a := p.FloatAt(x, y)
+	channel := func(v float64) uint16 {
+		return uint16(util.Clampf(0, 1, v)*util.MaxRange + 0.5)
+	}
+	return color.RGBA64{R: channel(r), G: channel(g), B: channel(b), A: channel(a)}
+}
+
+// Set implements draw.Image interface.
+func (p *FloatImage) Set(x, y int, c color.Color) {
+	r, g, b, a := c.RGBA()
+	p.SetFloat(x, y, util.Color(r), util.Color(g), util.Color(b), util.Color(a))
+}
+
+// FloatAt returns the channels of the pixel at (x, y).
+func (p *FloatImage) FloatAt(x, y int) (r, g, b, a float64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	return s[0], s[1], s[2], s[3]
+}
+
+// SetFloat sets the channels of the pixel at (x, y).
+func (p *FloatImage) SetFloat(x, y int, r, g, b, a float64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	s[0], s[1], s[2], s[3] = r, g, b, a
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds to the pixel at (x, y).
+func (p *FloatImage) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*4
+}
+
+// pixel returns the colors and the alpha of the pixel (x, y) of the given image.
+// FloatImage and FloatMap are read without quantization.
+func pixel(m image.Image, x, y int) (rgb [3]float64, a float64) {
+	switch fm := m.(type) {
+	case *FloatImage:
+		r, g, b, a := fm.FloatAt(x, y)
+		return [3]float64{r, g, b}, a
+	case *FloatMap:
+		v := float64(fm.FloatAt(x, y))
+		return [3]float64{v, v, v}, 1
+	}
+
+	r, g, b, a32 := m.At(x, y).RGBA()
+	return [3]float64{util.Color(r), util.Color(g), util.Color(b)}, util.Color(a32)
+}
@@ -0,0 +1,50 @@
+package bilateral_test
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestFloatImage(t *testing.T) {
+	mi := images["base"]
+
+	fm := bilateral.ToFloatImage(mi)
+	if !reflect.DeepEqual(fm.Bounds(), mi.Bounds()) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "Bounds", mi.Bounds(), fm.Bounds())
+	}
+
+	for y := 0; y < mi.Bounds().Dy(); y++ {
+		for x := 0; x < mi.Bounds().Dx(); x++ {
+			expected := color.RGBA64Model.Convert(mi.At(x, y))
+			if !reflect.DeepEqual(fm.At(x, y), expected) {
+				t.Errorf("%s(%d,%d): expected: %#v, actual: %#v", "At", x, y, expected, fm.At(x, y))
+			}
+		}
+	}
+}
+
+func TestFastBilateralResultFloat(t *testing.T) {
+	mi := images["base-gray"]
+	mo := images["base-gray-filtered"]
+
+	// Float input gives the same result as its 8-bit source
+	filter := bilateral.Auto(bilateral.ToFloatImage(mi))
+	filter.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "ResultImage", mo, filter.ResultImage())
+	}
+
+	fm := filter.ResultFloat()
+	for y := 0; y < mi.Bounds().Dy(); y++ {
+		for x := 0; x < mi.Bounds().Dx(); x++ {
+			r, _, _, _ := fm.FloatAt(x, y)
+			if expected := mo.RGBAAt(x, y).R; uint8(r*255) != expected {
+				t.Errorf("%s(%d,%d): expected: %d, actual: %f", "FloatAt", x, y, expected, r*255)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package bilateral
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// A FloatMap is an in-memory single-channel map of float32 values, like a depth map, a disparity map or a mask.
+// It is read and written by the filters without any quantization nor color conversion.
+// As an image.Image, it is a gray image whose values are clamped to [0, 1].
+type FloatMap struct {
+	// Pix holds the map's values.
+	// The value at (x, y) is Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)].
+	Pix []float32
+	// Stride is the Pix stride between two vertically adjacent values.
+	Stride int
+	// Rect is the map's bounds.
+	Rect image.Rectangle
+}
+
+// NewFloatMap returns a new FloatMap with the given bounds.
+func NewFloatMap(r image.Rectangle) *FloatMap {
+	return &FloatMap{
+		Pix:    make([]float32, r.Dx()*r.Dy()),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+}
+
+// WrapFloatMap returns a FloatMap of the given size sharing the given values, whose rows are stride values apart.
+// It returns ErrMapSize if pix is too short.
+func WrapFloatMap(pix []float32, width, height, stride int) (*FloatMap, error) {
+	if width < 0 || height < 0 || stride < width || (height > 0 && len(pix) < (height-1)*stride+width) {
+		return nil, ErrMapSize
+	}
+	return &FloatMap{
+		Pix:    pix,
+		Stride: stride,
+		Rect:   image.Rect(0, 0, width, height),
+	}, nil
+}
+
+// ColorModel implements image.Image interface.
+func (p *FloatMap) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+// Bounds implements image.Image interface.
+func (p *FloatMap) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At implements image.Image interface.
+func (p *FloatMap) At(x, y int) color.Color {
+	return color.Gray16{Y: uint16(util.Clampf(0, 1, float64(p.FloatAt(x, y)))*util.MaxRange + 0.5)}
+}
+
+// Set implements draw.Image interface.
+func (p *FloatMap) Set(x, y int, c color.Color) {
+	p.SetFloat(x, y, float32(util.Color(uint32(color.Gray16Model.Convert(c).(color.Gray16).Y))))
+}
+
+// FloatAt returns the value at (x, y).
+func (p *FloatMap) FloatAt(x, y int) float32 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return 0
+	}
+	return p.Pix[p.PixOffset(x, y)]
+}
+
+// SetFloat sets the value at (x, y).
+func (p *FloatMap) SetFloat(x, y int, v float32) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = v
+}
+
+// PixOffset returns the index of the element of Pix that corresponds to the value at (x, y).
+func (p *FloatMap) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
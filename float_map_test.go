@@ -0,0 +1,99 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestFloatMap(t *testing.T) {
+	if _, err := bilateral.WrapFloatMap(make([]float32, 10), 4, 3, 4); err != bilateral.ErrMapSize {
+		t.Errorf("%s: expected: %v, actual: %v", "WrapFloatMap", bilateral.ErrMapSize, err)
+	}
+
+	// Padded rows
+	m, err := bilateral.WrapFloatMap(make([]float32, 14), 4, 3, 5)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "WrapFloatMap", nil, err)
+	}
+	m.SetFloat(3, 2, 1.5)
+	if m.Pix[13] != 1.5 {
+		t.Errorf("%s: expected: %v, actual: %v", "SetFloat", 1.5, m.Pix[13])
+	}
+	if m.At(3, 2) != (color.Gray16{Y: 0xffff}) {
+		t.Errorf("%s: expected: %v, actual: %v", "At", color.Gray16{Y: 0xffff}, m.At(3, 2))
+	}
+
+	m.Set(0, 0, color.Gray{Y: 0xff})
+	if m.FloatAt(0, 0) != 1 {
+		t.Errorf("%s: expected: %v, actual: %v", "Set", 1, m.FloatAt(0, 0))
+	}
+}
+
+func TestFastBilateralResultMap(t *testing.T) {
+	mi := images["base-gray"]
+	d := mi.Bounds()
+
+	m := bilateral.NewFloatMap(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			m.SetFloat(x, y, float32(mi.RGBAAt(x, y).R)/255)
+		}
+	}
+
+	filter := bilateral.New(m, 16, 0.1)
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	result := filter.ResultMap()
+
+	expected := bilateral.New(mi, 16, 0.1)
+	expected.Execute()
+	fm := expected.ResultFloat()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			r, _, _, _ := fm.FloatAt(x, y)
+			if v := result.FloatAt(x, y); math.Abs(float64(v)-r) > 1e-4 {
+				t.Fatalf("%s(%d,%d): expected: %v, actual: %v", "ResultMap", x, y, r, v)
+			}
+		}
+	}
+}
+
+func TestFastBilateralPercentilesFloatMap(t *testing.T) {
+	// Depth map stepping from 10 to 30, with two far outliers
+	m := bilateral.NewFloatMap(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			m.SetFloat(x, y, 10)
+			if x >= 16 {
+				m.SetFloat(x, y, 30)
+			}
+		}
+	}
+	m.SetFloat(4, 4, 1000)
+	m.SetFloat(28, 28, 1000)
+
+	filter := bilateral.New(m, 4, 1)
+	filter.Percentiles = bilateral.Percentiles{Low: 0.01, High: 0.99}
+	// Range bounds [10, 30]: 21 range cells and the padding
+	if depth := filter.Footprint().Size[2]; depth < 24 || depth > 25 {
+		t.Errorf("%s: expected: %v, actual: %v", "Depth", "24 or 25", depth)
+	}
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	result := filter.ResultMap()
+	for _, p := range []struct {
+		x, y     int
+		expected float64
+	}{{12, 16, 10}, {15, 16, 10}, {16, 16, 30}, {20, 16, 30}} {
+		if v := float64(result.FloatAt(p.x, p.y)); math.Abs(v-p.expected) > 0.1 {
+			t.Errorf("%s(%d,%d): expected: %v, actual: %v", "ResultMap", p.x, p.y, p.expected, v)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package bilateral
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+const (
+	// Allocated bytes per grid cell, without the colors' data:
+	// cell pointer (8), cell (16) and mat.VecDense (48 in its size class).
+	cellOverhead = 8 + 16 + 48
+	// Grids allocated during Execute (grid and convolution buffer).
+	grids = 2
+	// Sigmas' growth factor at each coarsening step.
+	coarsenFactor = 1.25
+	// Upper bound of coarsening steps (~2^24 growth).
+	coarsenSteps = 75
+)
+
+// ErrMemoryLimit is returned when the grids do not fit the memory limit.
+var ErrMemoryLimit = errors.New("bilateral: memory limit exceeded")
+
+// A Footprint describes the grids allocated by a filter.
+type Footprint struct {
+	// Grid dimensions: width, height and range depths.
+	Size []int
+	// Number of cells of one grid.
+	Cells int
+	// Estimated memory usage in bytes.
+	Bytes int64
+}
+
+// String implements fmt.Stringer interface.
+func (fp Footprint) String() string {
+	return fmt.Sprintf("%v (%d cells, %d bytes)", fp.Size, fp.Cells, fp.Bytes)
+}
+
+// Footprint predicts the grid dimensions and the memory allocated by Execute for the current parameters.
+// It scans the image to compute the range bounds, the scan is not repeated by Execute.
+func (f *FastBilateral) Footprint() Footprint {
+	f.minmaxOnce.Do(f.minmax)
+	f.resize()
+
+	size := make([]int, len(f.size))
+	copy(size, f.size)
+	cells := util.Mul(size...)
+	return Footprint{
+		Size:  size,
+		Cells: cells,
+		Bytes: int64(grids) * int64(cells) * int64(cellOverhead+8*f.channels()),
+	}
+}
+
+// fit computes the grid size and ensures it fits MaxMemory, coarsening the sampling if allowed.
+func (f *FastBilateral) fit() error {
+	fp := f.Footprint()
+	if f.MaxMemory <= 0 || fp.Bytes <= f.MaxMemory {
+		return nil
+	}
+
+	if f.Coarsen {
+		for i := 0; i < coarsenSteps && fp.Bytes > f.MaxMemory; i++ {
+			f.SigmaSpace *= coarsenFactor
+			f.SigmaSpaceY *= coarsenFactor
+			f.SigmaRange *= coarsenFactor
+			f.SigmaRanges = scale(coarsenFactor, f.SigmaRanges)
+			fp = f.Footprint()
+		}
+		if fp.Bytes <= f.MaxMemory {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: grid %v needs %d bytes, %d allowed", ErrMemoryLimit, fp.Size, fp.Bytes, f.MaxMemory)
+}
@@ -0,0 +1,133 @@
+package bilateral_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestFootprint(t *testing.T) {
+	mi := images["base-gray"]
+
+	filter := bilateral.New(mi, 4, 0.1)
+	fp := filter.Footprint()
+
+	// Width: 19/4+1+4, Height: 18/4+1+4, Gray depth: (max-min)/0.1+1+4
+	if !reflect.DeepEqual(fp.Size[0:2], []int{9, 9}) || len(fp.Size) != 3 {
+		t.Errorf("%s: expected: %v, actual: %v", "Size", "[9 9 z]", fp.Size)
+	}
+	if fp.Cells != fp.Size[0]*fp.Size[1]*fp.Size[2] {
+		t.Errorf("%s: expected: %d, actual: %d", "Cells", fp.Size[0]*fp.Size[1]*fp.Size[2], fp.Cells)
+	}
+	if fp.Bytes <= 0 {
+		t.Errorf("%s: expected positive, actual: %d", "Bytes", fp.Bytes)
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	mi := images["base"]
+
+	filter := bilateral.New(mi, 2, 0.01)
+	filter.MaxMemory = 1 << 20
+	if err := filter.Execute(); !errors.Is(err, bilateral.ErrMemoryLimit) {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", bilateral.ErrMemoryLimit, err)
+	}
+
+	filter.Coarsen = true
+	if err := filter.Execute(); err != nil {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	if fp := filter.Footprint(); fp.Bytes > filter.MaxMemory {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Bytes", filter.MaxMemory, fp.Bytes)
+	}
+	if filter.SigmaSpace <= 2 || filter.SigmaRange <= 0.01 {
+		t.Errorf("%s: expected coarser sigmas, actual: %f %f", "Coarsen", filter.SigmaSpace, filter.SigmaRange)
+	}
+}
+
+func TestFootprintSigmaRanges(t *testing.T) {
+	mi := images["base"]
+
+	uniform := bilateral.New(mi, 8, 0.1).Footprint()
+
+	filter := bilateral.New(mi, 8, 0.1)
+	filter.SigmaRanges = []float64{0, 0.2, 0.05}
+	fp := filter.Footprint()
+
+	if fp.Size[2] != uniform.Size[2] {
+		t.Errorf("%s: expected: %d, actual: %d", "Red depth", uniform.Size[2], fp.Size[2])
+	}
+	if fp.Size[3] >= uniform.Size[3] {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Green depth", uniform.Size[3], fp.Size[3])
+	}
+	if fp.Size[4] <= uniform.Size[4] {
+		t.Errorf("%s: expected greater than %d, actual: %d", "Blue depth", uniform.Size[4], fp.Size[4])
+	}
+
+	if err := filter.Execute(); err != nil {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+}
+
+func TestSigmaRanges(t *testing.T) {
+	// Noisy red channel over green and blue vertical edges
+	mi := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			c := color.RGBA{R: 96, A: 255}
+			if (x+y)%2 == 0 {
+				c.R = 160
+			}
+			if x >= 16 {
+				c.G, c.B = 255, 255
+			}
+			mi.SetRGBA(x, y, c)
+		}
+	}
+
+	// Largest red difference between horizontal neighbours, away from the image borders
+	noise := func(m image.Image) (amplitude int) {
+		for y := 4; y < 12; y++ {
+			for x := 4; x < 28; x++ {
+				r0, _, _, _ := m.At(x, y).RGBA()
+				r1, _, _, _ := m.At(x+1, y).RGBA()
+				d := int(r0>>8) - int(r1>>8)
+				if d < 0 {
+					d = -d
+				}
+				if d > amplitude {
+					amplitude = d
+				}
+			}
+		}
+		return amplitude
+	}
+
+	uniform := bilateral.New(mi, 4, 0.1)
+	uniform.Execute()
+	if actual := noise(uniform); actual < 32 {
+		t.Errorf("%s: expected greater than %d, actual: %d", "Uniform red noise", 32, actual)
+	}
+
+	filter := bilateral.New(mi, 4, 0.1)
+	filter.SigmaRanges = []float64{1}
+	filter.Execute()
+	if actual := noise(filter); actual > 4 {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Red noise", 4, actual)
+	}
+
+	for _, x := range []int{15, 16} {
+		_, g, b, _ := mi.At(x, 8).RGBA()
+		_, fg, fb, _ := filter.At(x, 8).RGBA()
+		if diff := int(g>>8) - int(fg>>8); diff < -8 || diff > 8 {
+			t.Errorf("%s[%d]: expected: %d, actual: %d", "Green edge", x, g>>8, fg>>8)
+		}
+		if diff := int(b>>8) - int(fb>>8); diff < -8 || diff > 8 {
+			t.Errorf("%s[%d]: expected: %d, actual: %d", "Blue edge", x, b>>8, fb>>8)
+		}
+	}
+}
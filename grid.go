@@ -2,7 +2,9 @@ package bilateral
 
 import (
 	"fmt"
+	"math/big"
 
+	"github.com/mdouchement/bilateral/internal/util"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -34,7 +36,7 @@ func newGrid(size []int, n int) *grid {
 	for x := range cells {
 		cells[x] = make([][]*cell, size[yi])
 		for y := range cells[x] {
-			cells[x][y] = make([]*cell, mul(size[zi:]...))
+			cells[x][y] = make([]*cell, util.Mul(size[zi:]...))
 			for z := range cells[x][y] {
 				cells[x][y][z] = &cell{colors: mat.NewVecDense(n, nil)}
 			}
@@ -58,11 +60,141 @@ func (g *grid) At(offsets ...int) *cell {
 
 	offset := offsets[zi] // z1
 	for i, v := range offsets[zi+1:] {
-		offset += v * mul(g.size[zi:zi+i+1]...) // z2, zi...
+		offset += v * util.Mul(g.size[zi:zi+i+1]...) // z2, zi...
 	}
 	return g.cells[offsets[xi]][offsets[yi]][offset]
 }
 
+// Lookup returns the cell at the given offsets or nil if they are out of the grid.
+func (g *grid) Lookup(offsets ...int) *cell {
+	for i, v := range offsets {
+		if v < 0 || v >= g.size[i] {
+			return nil
+		}
+	}
+	return g.At(offsets...)
+}
+
+// interior calls fn with the offsets of each cell which is not on the grid's border.
+// The offsets slice is reused between calls.
+func (g *grid) interior(fn func(offsets []int)) {
+	offsets := make([]int, len(g.size))
+	for i, s := range g.size {
+		if s < 3 {
+			return
+		}
+		offsets[i] = 1
+	}
+
+	for {
+		fn(offsets)
+
+		i := len(offsets) - 1
+		for ; i >= 0; i-- {
+			offsets[i]++
+			if offsets[i] < g.size[i]-1 {
+				break
+			}
+			offsets[i] = 1
+		}
+		if i < 0 {
+			return
+		}
+	}
+}
+
+// convolve blurs the grid along each axis with the given kernels and returns the blurred grid.
+// The grid is used as buffer.
+// The optional progress func is called after each pass.
+func (g *grid) convolve(kernels []Kernel, channels int, progress func(pass, passes int)) *grid {
+	buffer := newGrid(g.size, channels)
+	sum := &cell{colors: mat.NewVecDense(channels, nil)}
+	neighbour := make([]int, len(g.size))
+
+	passes := 0
+	for axis := range g.size {
+		passes += kernel(kernels, axis).Iterations()
+	}
+	pass := 0
+
+	for axis := range g.size { // spatial and range axes
+		k := kernel(kernels, axis)
+
+		for n := 0; n < k.Iterations(); n++ { // passes
+			g, buffer = buffer, g
+
+			g.interior(func(offsets []int) {
+				vg := g.At(offsets...)
+				vg.Scale(k.Weights[0], buffer.At(offsets...))
+
+				// weight * (prev + next), out of grid taps are zeros
+				copy(neighbour, offsets)
+				for i := 1; i <= k.Radius(); i++ {
+					neighbour[axis] = offsets[axis] - i
+					prev := buffer.Lookup(neighbour...)
+					neighbour[axis] = offsets[axis] + i
+					next := buffer.Lookup(neighbour...)
+
+					switch {
+					case prev != nil && next != nil:
+						sum.Add(prev, next)
+						vg.AddScaled(vg, k.Weights[i], sum)
+					case prev != nil:
+						vg.AddScaled(vg, k.Weights[i], prev)
+					case next != nil:
+						vg.AddScaled(vg, k.Weights[i], next)
+					}
+				}
+			})
+
+			pass++
+			if progress != nil {
+				progress(pass, passes)
+			}
+		}
+	}
+	return g
+}
+
+// linear performs a multi-linear interpolation of the grid at the given offsets.
+func (g *grid) linear(channels int, offset ...float64) *cell {
+	dimension := len(g.size)
+	permutations := 1 << uint(dimension)
+	index := make([]int, dimension)
+	indexx := make([]int, dimension)
+	alpha := make([]float64, dimension)
+
+	for n, s := range g.size {
+		off := offset[n]
+		size := s - 1
+		index[n] = util.Clamp(0, size, int(off))
+		indexx[n] = util.Clamp(0, size, index[n]+1)
+		alpha[n] = off - float64(index[n])
+	}
+
+	// Interpolation
+	c := &cell{colors: mat.NewVecDense(channels, nil)}
+	bitset := big.NewInt(int64(0)) // Use to perform all the interpolation's permutations
+	off := make([]int, dimension)
+	var scale float64
+	for i := 0; i < permutations; i++ {
+		bitset.SetUint64(uint64(i))
+		scale = 1.0
+		for n := 0; n < dimension; n++ {
+			if bitset.Bit(n) == 1 {
+				off[n] = index[n]
+				scale *= 1.0 - alpha[n]
+			} else {
+				off[n] = indexx[n]
+				scale *= alpha[n]
+			}
+		}
+		c.AddScaled(c, scale, g.At(off...))
+	}
+
+	return c
+}
+
 func (c *cell) Add(a, b *cell) {
 	c.colors.AddVec(a.colors, b.colors)
 	c.threshold = a.threshold + b.threshold
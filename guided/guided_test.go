@@ -0,0 +1,170 @@
+package guided_test
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/guided"
+)
+
+// step returns an image whose left half is dark and right half is bright, covered by a fine texture.
+func step(texture uint8, c func(v uint8) color.Color) image.Image {
+	m := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := uint8(40)
+			if x >= 16 {
+				v = 200
+			}
+			if (x+y)%2 == 0 {
+				v += texture
+			}
+			m.Set(x, y, c(v))
+		}
+	}
+	return m
+}
+
+func gray(v uint8) color.Color {
+	return color.Gray{Y: v}
+}
+
+func teal(v uint8) color.Color {
+	return color.RGBA{R: v / 4, G: v, B: v / 2, A: 255}
+}
+
+func TestFilterConstant(t *testing.T) {
+	m := image.NewUniform(color.RGBA{R: 10, G: 100, B: 200, A: 255})
+	mi := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			mi.Set(x, y, m.At(x, y))
+		}
+	}
+
+	filter := guided.New(mi, 2, 0.01)
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	result := filter.ResultFloat()
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			r, g, b, _ := result.FloatAt(x, y)
+			if math.Abs(r-10.0/255) > 1e-9 || math.Abs(g-100.0/255) > 1e-9 || math.Abs(b-200.0/255) > 1e-9 {
+				t.Errorf("%s: expected: %v, actual: %v", "FloatAt", mi.At(x, y), []float64{r * 255, g * 255, b * 255})
+			}
+		}
+	}
+}
+
+func TestFilterEdges(t *testing.T) {
+	for name, c := range map[string]func(uint8) color.Color{"gray": gray, "color": teal} {
+		filter := guided.New(step(12, c), 3, 0.01)
+		if err := filter.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", name, nil, err)
+		}
+		result := filter.ResultFloat()
+
+		// The texture is smoothed
+		_, a, _, _ := result.FloatAt(6, 8)
+		_, b, _, _ := result.FloatAt(7, 8)
+		if d := math.Abs(a - b); d > 0.01 {
+			t.Errorf("%s: expected: %v, actual: %v", name+" texture", "< 0.01", d)
+		}
+
+		// The edge is kept
+		_, a, _, _ = result.FloatAt(13, 8)
+		_, b, _, _ = result.FloatAt(18, 8)
+		if e := b - a; e < 0.5 {
+			t.Errorf("%s: expected: %v, actual: %v", name+" edge", "> 0.5", e)
+		}
+
+		// No gradient reversal: across the edge, the texture's local mean rises monotonically
+		// between the means of the two halves, without overshooting them
+		low, high := 46.0/255, 206.0/255
+		previous := low
+		for x := 0; x < 32; x++ {
+			_, a, _, _ := result.FloatAt(x, 8)
+			_, b, _, _ := result.FloatAt(x, 9)
+			mean := (a + b) / 2
+			if mean < previous-1e-6 || mean > high+1e-6 {
+				t.Errorf("%s(%d): expected: %v, actual: %v", name+" mean", x, fmt.Sprintf("in [%f, %f]", previous, high), mean)
+			}
+			previous = math.Max(previous, mean)
+		}
+	}
+}
+
+func TestFilterGuide(t *testing.T) {
+	// A flat image guided by an edge stays flat
+	mi := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for i := range mi.Pix {
+		mi.Pix[i] = 128
+	}
+
+	filter := guided.New(mi, 3, 0.01)
+	filter.Guide = step(0, gray)
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	if r, _, _, _ := filter.ResultFloat().FloatAt(10, 10); math.Abs(r-128.0/255) > 1e-9 {
+		t.Errorf("%s: expected: %v, actual: %v", "FloatAt", 128.0/255, r)
+	}
+
+	filter.Guide = image.NewGray(image.Rect(0, 0, 4, 4))
+	if err := filter.Execute(); err != guided.ErrGuideBounds {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", guided.ErrGuideBounds, err)
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	mi := step(0, gray)
+
+	for name, epsilon := range map[string]float64{
+		"Zero epsilon":     0,
+		"NaN epsilon":      math.NaN(),
+		"Infinite epsilon": math.Inf(1),
+	} {
+		if err := guided.New(mi, 3, epsilon).Execute(); err != guided.ErrEpsilon {
+			t.Errorf("%s: expected: %v, actual: %v", name, guided.ErrEpsilon, err)
+		}
+	}
+	if err := guided.New(mi, -1, 0.01).Execute(); err != guided.ErrRadius {
+		t.Errorf("%s: expected: %v, actual: %v", "Radius", guided.ErrRadius, err)
+	}
+	if err := guided.New(mi, 3, math.NaN()).Execute(); !errors.Is(err, bilateral.ErrInvalidOption) {
+		t.Errorf("%s: expected: %v, actual: %v", "Invalid option", bilateral.ErrInvalidOption, err)
+	}
+}
+
+func TestAuto(t *testing.T) {
+	filter := guided.Auto(step(12, teal))
+	if filter.Radius < 1 || filter.Epsilon <= 0 {
+		t.Errorf("%s: expected: %v, actual: %d %f", "Parameters", "positive", filter.Radius, filter.Epsilon)
+	}
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	if !filter.Bounds().Eq(filter.ResultImage().Bounds()) {
+		t.Errorf("%s: expected: %v, actual: %v", "Bounds", filter.Bounds(), filter.ResultImage().Bounds())
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	filter, err := bilateral.NewFilter("guided", step(12, teal), 3, 0.1)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewFilter", nil, err)
+	}
+	if _, ok := filter.(*guided.Filter); !ok {
+		t.Errorf("%s: expected: %T, actual: %T", "NewFilter", &guided.Filter{}, filter)
+	}
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+}
@@ -0,0 +1,198 @@
+package guided
+
+import "github.com/mdouchement/bilateral/internal/util"
+
+// A window computes the means over the square windows of a plane with summed-area tables.
+// Windows are cropped at the plane's borders.
+type window struct {
+	w, h   int
+	radius int
+	// Number of pixels of each cropped window.
+	count []float64
+	sat   []float64
+}
+
+func newWindow(w, h, radius int) *window {
+	win := &window{
+		w:      w,
+		h:      h,
+		radius: radius,
+		sat:    make([]float64, (w+1)*(h+1)),
+	}
+
+	ones := make([]float64, w*h)
+	for i := range ones {
+		ones[i] = 1
+	}
+	win.count = win.sum(ones)
+	return win
+}
+
+// sum returns the sum of each window of the given plane.
+func (win *window) sum(plane []float64) []float64 {
+	w, h, r := win.w, win.h, win.radius
+	stride := w + 1
+	for y := 0; y < h; y++ {
+		row := 0.0
+		for x := 0; x < w; x++ {
+			row += plane[y*w+x]
+			win.sat[(y+1)*stride+x+1] = win.sat[y*stride+x+1] + row
+		}
+	}
+
+	sums := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		y0, y1 := util.Clamp(0, h, y-r), util.Clamp(0, h, y+r+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := util.Clamp(0, w, x-r), util.Clamp(0, w, x+r+1)
+			sums[y*w+x] = win.sat[y1*stride+x1] - win.sat[y0*stride+x1] - win.sat[y1*stride+x0] + win.sat[y0*stride+x0]
+		}
+	}
+	return sums
+}
+
+// mean returns the mean of each window of the given plane.
+func (win *window) mean(plane []float64) []float64 {
+	means := win.sum(plane)
+	for i := range means {
+		means[i] /= win.count[i]
+	}
+	return means
+}
+
+// meanProduct returns the mean of each window of the product of the given planes.
+func (win *window) meanProduct(a, b []float64) []float64 {
+	product := make([]float64, len(a))
+	for i := range product {
+		product[i] = a[i] * b[i]
+	}
+	return win.mean(product)
+}
+
+// gray returns the plane p filtered with the gray guide I.
+func (win *window) gray(I, p []float64, epsilon float64) []float64 {
+	meanI := win.mean(I)
+	meanP := win.mean(p)
+	corrI := win.meanProduct(I, I)
+	corrIp := win.meanProduct(I, p)
+
+	a := make([]float64, len(I))
+	b := make([]float64, len(I))
+	for i := range a {
+		varI := corrI[i] - meanI[i]*meanI[i]
+		covIp := corrIp[i] - meanI[i]*meanP[i]
+		a[i] = covIp / (varI + epsilon)
+		b[i] = meanP[i] - a[i]*meanI[i]
+	}
+
+	meanA := win.mean(a)
+	meanB := win.mean(b)
+	q := make([]float64, len(I))
+	for i := range q {
+		q[i] = meanA[i]*I[i] + meanB[i]
+	}
+	return q
+}
+
+// color returns the red, green and blue planes of p filtered with the colour guide I.
+func (win *window) color(I, p [4][]float64, epsilon float64) (q [3][]float64) {
+	n := len(I[0])
+
+	var meanI [3][]float64
+	for k := range meanI {
+		meanI[k] = win.mean(I[k])
+	}
+
+	// Inverse of the regularized covariance matrix of the guide, symmetric:
+	// rr rg rb
+	// rg gg gb
+	// rb gb bb
+	var sigma [3][3][]float64
+	for j := 0; j < 3; j++ {
+		for k := j; k < 3; k++ {
+			sigma[j][k] = win.meanProduct(I[j], I[k])
+			for i := range sigma[j][k] {
+				sigma[j][k][i] -= meanI[j][i] * meanI[k][i]
+				if j == k {
+					sigma[j][k][i] += epsilon
+				}
+			}
+			sigma[k][j] = sigma[j][k]
+		}
+	}
+
+	var inv [3][3][]float64
+	for j := range inv {
+		for k := range inv[j] {
+			inv[j][k] = make([]float64, n)
+		}
+	}
+	for i := 0; i < n; i++ {
+		var m [3][3]float64
+		for j := range m {
+			for k := range m[j] {
+				m[j][k] = sigma[j][k][i]
+			}
+		}
+		mi := inverse(m)
+		for j := range mi {
+			for k := range mi[j] {
+				inv[j][k][i] = mi[j][k]
+			}
+		}
+	}
+
+	for c := range q {
+		meanP := win.mean(p[c])
+		var cov [3][]float64
+		for k := range cov {
+			cov[k] = win.meanProduct(I[k], p[c])
+			for i := range cov[k] {
+				cov[k][i] -= meanI[k][i] * meanP[i]
+			}
+		}
+
+		var a [3][]float64
+		for k := range a {
+			a[k] = make([]float64, n)
+		}
+		b := make([]float64, n)
+		for i := 0; i < n; i++ {
+			b[i] = meanP[i]
+			for j := range a {
+				a[j][i] = inv[j][0][i]*cov[0][i] + inv[j][1][i]*cov[1][i] + inv[j][2][i]*cov[2][i]
+				b[i] -= a[j][i] * meanI[j][i]
+			}
+		}
+
+		for k := range a {
+			a[k] = win.mean(a[k])
+		}
+		q[c] = win.mean(b)
+		for i := range q[c] {
+			q[c][i] += a[0][i]*I[0][i] + a[1][i]*I[1][i] + a[2][i]*I[2][i]
+		}
+	}
+	return
+}
+
+// inverse returns the inverse of the given symmetric positive definite matrix.
+func inverse(m [3][3]float64) (inv [3][3]float64) {
+	inv[0][0] = m[1][1]*m[2][2] - m[1][2]*m[2][1]
+	inv[0][1] = m[0][2]*m[2][1] - m[0][1]*m[2][2]
+	inv[0][2] = m[0][1]*m[1][2] - m[0][2]*m[1][1]
+	inv[1][0] = m[1][2]*m[2][0] - m[1][0]*m[2][2]
+	inv[1][1] = m[0][0]*m[2][2] - m[0][2]*m[2][0]
+	inv[1][2] = m[0][2]*m[1][0] - m[0][0]*m[1][2]
+	inv[2][0] = m[1][0]*m[2][1] - m[1][1]*m[2][0]
+	inv[2][1] = m[0][1]*m[2][0] - m[0][0]*m[2][1]
+	inv[2][2] = m[0][0]*m[1][1] - m[0][1]*m[1][0]
+
+	det := m[0][0]*inv[0][0] + m[0][1]*inv[1][0] + m[0][2]*inv[2][0]
+	for j := range inv {
+		for k := range inv[j] {
+			inv[j][k] /= det
+		}
+	}
+	return
+}
@@ -0,0 +1,71 @@
+package bilateral
+
+import "github.com/mdouchement/bilateral/internal/util"
+
+// Number of bins used to compute percentiles, one per 16-bit value.
+const histogramBins = util.MaxRange + 1
+
+type (
+	// Percentiles defines the low and high percentiles, in [0, 1], used as range bounds
+	// instead of the absolute minimum and maximum (e.g. {Low: 0.01, High: 0.99}).
+	// The zero value disables them.
+	Percentiles struct {
+		Low  float64
+		High float64
+	}
+
+	// A Histogram counts values in evenly spaced bins over [Min, Max].
+	// Values out of bounds are clamped into the edge bins.
+	Histogram struct {
+		Min   float64
+		Max   float64
+		Bins  []int
+		count int
+	}
+)
+
+// Enabled returns true if the percentiles are used as range bounds.
+func (p Percentiles) Enabled() bool {
+	return p.High > p.Low
+}
+
+// NewHistogram instanciates a new Histogram with n bins centered on Min, ..., Max.
+func NewHistogram(min, max float64, n int) *Histogram {
+	if n < 2 {
+		panic("Histogram must have at least 2 bins")
+	}
+
+	return &Histogram{
+		Min:  min,
+		Max:  max,
+		Bins: make([]int, n),
+	}
+}
+
+// Add counts the given value.
+func (h *Histogram) Add(v float64) {
+	i := int((v-h.Min)/h.width() + 0.5)
+	h.Bins[util.Clamp(0, len(h.Bins)-1, i)]++
+	h.count++
+}
+
+// Percentile returns the value below which the fraction p of the counted values falls.
+func (h *Histogram) Percentile(p float64) float64 {
+	if h.count == 0 {
+		return h.Min
+	}
+
+	rank := int(util.Clampf(0, 1, p) * float64(h.count-1))
+	var cumulative int
+	for i, n := range h.Bins {
+		cumulative += n
+		if cumulative > rank {
+			return h.Min + float64(i)*h.width()
+		}
+	}
+	return h.Max
+}
+
+func (h *Histogram) width() float64 {
+	return (h.Max - h.Min) / float64(len(h.Bins)-1)
+}
@@ -0,0 +1,86 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+// hotPixels returns an image stepping from 60 to 140 at x = 16,
+// with a few hot (255) and dead (0) pixels far out of the step's range.
+func hotPixels() *image.Gray {
+	m := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			m.SetGray(x, y, color.Gray{Y: 60})
+			if x >= 16 {
+				m.SetGray(x, y, color.Gray{Y: 140})
+			}
+		}
+	}
+	m.SetGray(4, 4, color.Gray{Y: 255})
+	m.SetGray(28, 28, color.Gray{Y: 255})
+	m.SetGray(28, 4, color.Gray{Y: 0})
+	return m
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := bilateral.NewHistogram(0, 1, 101)
+	for i := 0; i < 98; i++ {
+		h.Add(0.5)
+	}
+	h.Add(-3) // Outliers clamped into the edge bins
+	h.Add(7)
+
+	for _, tc := range []struct {
+		p        float64
+		expected float64
+	}{
+		{p: 0, expected: 0},
+		{p: 0.02, expected: 0.5},
+		{p: 0.5, expected: 0.5},
+		{p: 0.98, expected: 0.5},
+		{p: 1, expected: 1},
+	} {
+		if actual := h.Percentile(tc.p); actual != tc.expected {
+			t.Errorf("%s(%f): expected: %f, actual: %f", "Percentile", tc.p, tc.expected, actual)
+		}
+	}
+}
+
+func TestFastBilateralPercentiles(t *testing.T) {
+	mi := hotPixels()
+
+	depth := func(p bilateral.Percentiles) (int, *bilateral.FastBilateral) {
+		filter := bilateral.New(mi, 4, 0.05)
+		filter.Percentiles = p
+		size := filter.Footprint().Size
+		filter.Execute()
+		return size[len(size)-1], filter
+	}
+	full, _ := depth(bilateral.Percentiles{Low: 0, High: 1})
+	clipped, filter := depth(bilateral.Percentiles{Low: 0.01, High: 0.99})
+
+	// The hot and dead pixels no longer stretch the range axis
+	if clipped >= full {
+		t.Errorf("%s: expected: < %d, actual: %d", "Depth", full, clipped)
+	}
+
+	m := filter.ResultImage()
+	for _, p := range []struct {
+		x, y     int
+		expected uint8
+	}{
+		{x: 8, y: 20, expected: 60},
+		{x: 12, y: 10, expected: 60},
+		{x: 20, y: 20, expected: 140},
+		{x: 24, y: 12, expected: 140},
+	} {
+		r, _, _, _ := m.At(p.x, p.y).RGBA()
+		if diff := int(r>>8) - int(p.expected); diff < -2 || diff > 2 {
+			t.Errorf("%s(%d, %d): expected: %d, actual: %d", "At", p.x, p.y, p.expected, r>>8)
+		}
+	}
+}
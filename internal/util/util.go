@@ -0,0 +1,87 @@
+// Package util holds the numeric and color helpers shared by the filters' packages.
+package util
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// MaxRange is the maximum value of a 16-bit color channel.
+const MaxRange = 65535
+
+// Clamp returns v bounded to [min, max].
+func Clamp(min, max, v int) int {
+	if v < min {
+		v = min
+	}
+	if v > max {
+		v = max
+	}
+	return v
+}
+
+// Clampf returns v bounded to [min, max].
+func Clampf(min, max, v float64) float64 {
+	return math.Min(math.Max(v, min), max)
+}
+
+// Mul returns the product of the given sizes, ignoring the zero ones.
+func Mul(size ...int) (n int) {
+	n = 1
+	for _, v := range size {
+		if v != 0 {
+			n *= v
+		}
+	}
+	return
+}
+
+// Color converts the given 16-bit color channel to [0, 1].
+func Color(v uint32) float64 {
+	return float64(v) / MaxRange
+}
+
+// Nearest returns, for each pixel of r in rows order, the index in r of the nearest pixel
+// (4-connected breadth-first) for which valid returns true, or -1 when there is none.
+func Nearest(r image.Rectangle, valid func(x, y int) bool) []int {
+	w, h := r.Dx(), r.Dy()
+	nearest := make([]int, w*h)
+	queue := make([]int, 0, w*h)
+	for i := range nearest {
+		nearest[i] = -1
+		if valid(r.Min.X+i%w, r.Min.Y+i/w) {
+			nearest[i] = i
+			queue = append(queue, i)
+		}
+	}
+
+	for head := 0; head < len(queue); head++ {
+		i := queue[head]
+		x, y := i%w, i/w
+		for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+			if n[0] < 0 || n[0] >= w || n[1] < 0 || n[1] >= h {
+				continue
+			}
+			if j := n[1]*w + n[0]; nearest[j] < 0 {
+				nearest[j] = nearest[i]
+				queue = append(queue, j)
+			}
+		}
+	}
+	return nearest
+}
+
+// RGBA8 converts the given channels in [0, 1] to an 8-bit color.
+// Colors are truncated and alpha is rounded.
+func RGBA8(r, g, b, a float64) color.RGBA {
+	channel := func(v float64) uint8 {
+		return uint8(Clamp(0, 255, int(v*255)))
+	}
+	return color.RGBA{
+		R: channel(r),
+		G: channel(g),
+		B: channel(b),
+		A: uint8(Clamp(0, 255, int(a*255+0.5))),
+	}
+}
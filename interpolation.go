@@ -0,0 +1,127 @@
+package bilateral
+
+import (
+	"gonum.org/v1/gonum/mat"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// Interpolation defines how the grid is sliced to compute the filtered pixels.
+type Interpolation int
+
+const (
+	// Linear performs a multi-linear interpolation along all the grid axes (default).
+	Linear Interpolation = iota
+	// Cubic performs a Catmull-Rom interpolation along the spatial axes and a linear one along the range axes.
+	// It removes the blocky artefacts of large SigmaSpace values.
+	Cubic
+)
+
+// String implements fmt.Stringer interface.
+func (i Interpolation) String() string {
+	switch i {
+	case Linear:
+		return "linear"
+	case Cubic:
+		return "cubic"
+	default:
+		return "unknown"
+	}
+}
+
+// slice interpolates the grid at the given offsets.
+func (f *FastBilateral) slice(offset ...float64) *cell {
+	if f.Interpolation == Cubic {
+		if c, ok := f.cubicInterpolation(offset...); ok {
+			return c
+		}
+	}
+	return f.nLinearInterpolation(offset...)
+}
+
+// cubicInterpolation performs a Catmull-Rom interpolation along x and y, and a linear one along the colors.
+// Catmull-Rom overshoots where the negative lobes fall on denser cells than the inner taps, as in sparse
+// areas of the grid, so the normalized colors are clamped to the range of the inner taps' ones.
+// It returns false when none of the inner taps holds a contribution.
+func (f *FastBilateral) cubicInterpolation(offset ...float64) (*cell, bool) {
+	indices := make([][]int, f.dimension)
+	weights := make([][]float64, f.dimension)
+	for n, s := range f.size {
+		i := int(offset[n])
+		alpha := offset[n] - float64(i)
+
+		if n < 2 { // x, y
+			w := catmullRom(alpha)
+			indices[n] = []int{util.Clamp(0, s-1, i-1), util.Clamp(0, s-1, i), util.Clamp(0, s-1, i+1), util.Clamp(0, s-1, i+2)}
+			weights[n] = w[:]
+			continue
+		}
+
+		indices[n] = []int{util.Clamp(0, s-1, i), util.Clamp(0, s-1, i+1)}
+		weights[n] = []float64{1 - alpha, alpha}
+	}
+
+	// Tensor product of all axes' taps
+	channels := f.channels()
+	c := &cell{colors: mat.NewVecDense(channels, nil)}
+	lo := make([]float64, channels)
+	hi := make([]float64, channels)
+	inner := false
+	taps := make([]int, f.dimension)
+	off := make([]int, f.dimension)
+	for {
+		scale := 1.0
+		for n, t := range taps {
+			off[n] = indices[n][t]
+			scale *= weights[n][t]
+		}
+		tap := f.grid.At(off...)
+		c.AddScaled(c, scale, tap)
+
+		if taps[0]%3 != 0 && taps[1]%3 != 0 && tap.threshold > 0 { // Inner taps
+			for i := range lo {
+				v := tap.colors.AtVec(i) / tap.threshold
+				if !inner || v < lo[i] {
+					lo[i] = v
+				}
+				if !inner || v > hi[i] {
+					hi[i] = v
+				}
+			}
+			inner = true
+		}
+
+		n := f.dimension - 1
+		for ; n >= 0; n-- {
+			taps[n]++
+			if taps[n] < len(indices[n]) {
+				break
+			}
+			taps[n] = 0
+		}
+		if n < 0 {
+			break
+		}
+	}
+
+	if !inner || c.threshold <= 0 {
+		return nil, false
+	}
+	for i := range lo {
+		v := util.Clampf(lo[i], hi[i], c.colors.AtVec(i)/c.threshold)
+		c.colors.SetVec(i, v*c.threshold)
+	}
+	return c, true
+}
+
+// catmullRom returns the weights of the 4 taps surrounding the position t in [0, 1).
+func catmullRom(t float64) [4]float64 {
+	t2 := t * t
+	t3 := t2 * t
+	return [4]float64{
+		(-t3 + 2*t2 - t) / 2,
+		(3*t3 - 5*t2 + 2) / 2,
+		(-3*t3 + 4*t2 + t) / 2,
+		(t3 - t2) / 2,
+	}
+}
@@ -0,0 +1,85 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestFastBilateralCubic(t *testing.T) {
+	// Vertical step edge, blurred by a range sigma larger than the step
+	mi := image.NewGray(image.Rect(0, 0, 64, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(64)
+			if x >= 32 {
+				v = 192
+			}
+			mi.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+
+	// Largest second difference along a row, kinks of the linear interpolation stand out
+	roughness := func(m image.Image) int {
+		var max int
+		for x := 1; x < 63; x++ {
+			p, _, _, _ := m.At(x-1, 4).RGBA()
+			c, _, _, _ := m.At(x, 4).RGBA()
+			n, _, _, _ := m.At(x+1, 4).RGBA()
+			d := int(p>>8) - 2*int(c>>8) + int(n>>8)
+			if d < 0 {
+				d = -d
+			}
+			if d > max {
+				max = d
+			}
+		}
+		return max
+	}
+
+	linear := bilateral.New(mi, 8, 10)
+	linear.Execute()
+
+	cubic := bilateral.New(mi, 8, 10)
+	cubic.Interpolation = bilateral.Cubic
+	cubic.Execute()
+
+	if l, c := roughness(linear.ResultImage()), roughness(cubic.ResultImage()); c >= l {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Cubic roughness", l, c)
+	}
+}
+
+func TestFastBilateralCubicSparse(t *testing.T) {
+	// Dense left half next to isolated pixels of low confidence, most cells of the right half are empty
+	mi := image.NewGray(image.Rect(0, 0, 64, 16))
+	confidence := image.NewGray(mi.Bounds())
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x++ {
+			switch {
+			case x < 32:
+				mi.SetGray(x, y, color.Gray{Y: 224})
+				confidence.SetGray(x, y, color.Gray{Y: 255})
+			case x%5 == 0 && y%5 == 0:
+				mi.SetGray(x, y, color.Gray{Y: 32})
+				confidence.SetGray(x, y, color.Gray{Y: 4})
+			}
+		}
+	}
+
+	filter := bilateral.New(mi, 4, 1)
+	filter.Confidence = confidence
+	filter.Interpolation = bilateral.Cubic
+	filter.Execute()
+
+	m := filter.ResultFloat()
+	d := m.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			if r, _, _, _ := m.FloatAt(x, y); r < 32.0/255-1e-6 || r > 224.0/255+1e-6 {
+				t.Fatalf("%s(%d, %d): expected within [%v, %v], actual: %v", "Red", x, y, 32.0/255, 224.0/255, r)
+			}
+		}
+	}
+}
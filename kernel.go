@@ -0,0 +1,195 @@
+package bilateral
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Gaussian kernels are truncated at this many sigmas.
+const kernelTruncate = 3
+
+// A Kernel is a symmetric 1-D kernel convolved along a grid axis.
+// Distances are expressed in grid cells, one cell being one sigma (SigmaSpace or SigmaRange).
+type Kernel struct {
+	// Weights from the center outwards: Weights[0] is the center tap and Weights[i] the taps at distance i.
+	Weights []float64
+	// Passes is the number of times the kernel is applied, at least 1.
+	Passes int
+}
+
+// BinomialKernel returns the default [1 2 1]/4 kernel applied twice,
+// equivalent to a Gaussian with a standard deviation of one cell.
+func BinomialKernel() Kernel {
+	return Kernel{Weights: []float64{0.5, 0.25}, Passes: 2}
+}
+
+// GaussianKernel returns a sampled Gaussian kernel with the given standard deviation in cells,
+// truncated at 3 sigmas and normalized.
+// A sigma that is not positive and finite returns the identity kernel.
+func GaussianKernel(sigma float64, passes int) Kernel {
+	if !positive(sigma) {
+		return identityKernel(passes)
+	}
+
+	radius := int(math.Ceil(kernelTruncate * sigma))
+	weights := make([]float64, radius+1)
+	sum := 0.0
+	for i := range weights {
+		weights[i] = math.Exp(-float64(i*i) / (2 * sigma * sigma))
+		sum += weights[i]
+		if i > 0 {
+			sum += weights[i] // Symmetric tap
+		}
+	}
+
+	for i := range weights {
+		weights[i] /= sum
+	}
+	return Kernel{Weights: weights, Passes: passes}
+}
+
+// BoxKernel returns a box kernel with the given radius in cells.
+// A negative radius returns the identity kernel.
+func BoxKernel(radius, passes int) Kernel {
+	if radius < 0 {
+		return identityKernel(passes)
+	}
+
+	weights := make([]float64, radius+1)
+	for i := range weights {
+		weights[i] = 1 / float64(2*radius+1)
+	}
+	return Kernel{Weights: weights, Passes: passes}
+}
+
+// identityKernel returns the kernel leaving the grid unchanged.
+func identityKernel(passes int) Kernel {
+	return Kernel{Weights: []float64{1}, Passes: passes}
+}
+
+// TukeyKernel returns Tukey's biweight kernel (1 - (d/scale)²)², zero from scale cells.
+// As a range kernel, it completely stops the diffusion across edges higher than scale cells.
+// A scale that is not positive and finite returns the identity kernel.
+func TukeyKernel(scale float64) Kernel {
+	if !positive(scale) {
+		return identityKernel(1)
+	}
+	return robustKernel(int(math.Ceil(scale)), func(d float64) float64 {
+		if d >= scale {
+			return 0
+		}
+		u := 1 - (d*d)/(scale*scale)
+		return u * u
+	})
+}
+
+// LorentzianKernel returns the Lorentzian (Cauchy) kernel 1 / (1 + d²/(2 scale²)), truncated at radius cells.
+// As a range kernel, its heavy tail lets the diffusion slowly leak across edges.
+// A scale that is not positive and finite returns the identity kernel.
+func LorentzianKernel(scale float64, radius int) Kernel {
+	if !positive(scale) {
+		return identityKernel(1)
+	}
+	return robustKernel(radius, func(d float64) float64 {
+		return 1 / (1 + (d*d)/(2*scale*scale))
+	})
+}
+
+// HuberKernel returns Huber's kernel, 1 up to scale cells then scale/d, truncated at radius cells.
+// As a range kernel, it averages the small differences and only damps the larger ones.
+// A scale that is not positive and finite returns the identity kernel.
+func HuberKernel(scale float64, radius int) Kernel {
+	if !positive(scale) {
+		return identityKernel(1)
+	}
+	return robustKernel(radius, func(d float64) float64 {
+		if d <= scale {
+			return 1
+		}
+		return scale / d
+	})
+}
+
+// robustKernel returns the normalized single pass kernel sampling the weight function of a robust estimator
+// up to the given radius, without its trailing zero taps.
+func robustKernel(radius int, weight func(d float64) float64) Kernel {
+	if radius < 0 {
+		radius = 0
+	}
+	weights := make([]float64, 0, radius+1)
+	sum := 0.0
+	for i := 0; i <= radius; i++ {
+		w := weight(float64(i))
+		if w == 0 {
+			break
+		}
+		weights = append(weights, w)
+		sum += w
+		if i > 0 {
+			sum += w // Symmetric tap
+		}
+	}
+
+	for i := range weights {
+		weights[i] /= sum
+	}
+	return Kernel{Weights: weights, Passes: 1}
+}
+
+// Validate returns an error if the weights are negative or not finite, or if they sum to zero.
+// A zero kernel is valid, it falls back to the default kernel.
+func (k Kernel) Validate() error {
+	if len(k.Weights) == 0 {
+		return nil
+	}
+	if k.Passes < 0 {
+		return fmt.Errorf("negative passes %d", k.Passes)
+	}
+
+	sum := 0.0
+	for i, w := range k.Weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("invalid weight %v at distance %d", w, i)
+		}
+		sum += w
+	}
+	if sum == 0 {
+		return errors.New("weights sum to zero")
+	}
+	return nil
+}
+
+// validateKernels returns an error wrapping ErrInvalidOption if one of the given kernels is invalid.
+func validateKernels(kernels []Kernel, rangeKernel Kernel) error {
+	for axis, k := range kernels {
+		if err := k.Validate(); err != nil {
+			return fmt.Errorf("%w: kernel of axis %d: %v", ErrInvalidOption, axis, err)
+		}
+	}
+	if err := rangeKernel.Validate(); err != nil {
+		return fmt.Errorf("%w: range kernel: %v", ErrInvalidOption, err)
+	}
+	return nil
+}
+
+// Radius returns the distance between the center and the outermost taps.
+func (k Kernel) Radius() int {
+	return len(k.Weights) - 1
+}
+
+// Iterations returns the number of passes, at least 1.
+func (k Kernel) Iterations() int {
+	if k.Passes < 1 {
+		return 1
+	}
+	return k.Passes
+}
+
+// kernel returns the kernel of the given axis, BinomialKernel by default.
+func kernel(kernels []Kernel, axis int) Kernel {
+	if axis < len(kernels) && len(kernels[axis].Weights) > 0 {
+		return kernels[axis]
+	}
+	return BinomialKernel()
+}
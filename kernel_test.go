@@ -0,0 +1,149 @@
+package bilateral_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestKernelNormalized(t *testing.T) {
+	for name, k := range map[string]bilateral.Kernel{
+		"Binomial":   bilateral.BinomialKernel(),
+		"Gaussian":   bilateral.GaussianKernel(1.5, 1),
+		"Box":        bilateral.BoxKernel(2, 3),
+		"Tukey":      bilateral.TukeyKernel(2.5),
+		"Lorentzian": bilateral.LorentzianKernel(1, 6),
+		"Huber":      bilateral.HuberKernel(1, 4),
+	} {
+		sum := k.Weights[0]
+		for _, w := range k.Weights[1:] {
+			sum += 2 * w
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Errorf("%s: expected: %f, actual: %f", name, 1.0, sum)
+		}
+	}
+
+	if r := bilateral.GaussianKernel(1.5, 1).Radius(); r != 5 {
+		t.Errorf("%s: expected: %d, actual: %d", "Radius", 5, r)
+	}
+	if r := bilateral.TukeyKernel(2).Radius(); r != 1 {
+		t.Errorf("%s: expected: %d, actual: %d", "Tukey radius", 1, r)
+	}
+}
+
+func TestKernelDegenerate(t *testing.T) {
+	identity := bilateral.Kernel{Weights: []float64{1}, Passes: 2}
+
+	for name, k := range map[string]bilateral.Kernel{
+		"Zero sigma":     bilateral.GaussianKernel(0, 2),
+		"Negative sigma": bilateral.GaussianKernel(-1, 2),
+		"NaN sigma":      bilateral.GaussianKernel(math.NaN(), 2),
+		"Negative box":   bilateral.BoxKernel(-1, 2),
+	} {
+		if !reflect.DeepEqual(k, identity) {
+			t.Errorf("%s: expected: %v, actual: %v", name, identity, k)
+		}
+	}
+
+	identity.Passes = 1
+	for name, k := range map[string]bilateral.Kernel{
+		"Zero Tukey":          bilateral.TukeyKernel(0),
+		"NaN Tukey":           bilateral.TukeyKernel(math.NaN()),
+		"Infinite Tukey":      bilateral.TukeyKernel(math.Inf(1)),
+		"Zero Lorentzian":     bilateral.LorentzianKernel(0, 3),
+		"Negative Lorentzian": bilateral.LorentzianKernel(-1, 3),
+		"NaN Huber":           bilateral.HuberKernel(math.NaN(), 3),
+		"Negative Huber":      bilateral.HuberKernel(-1, 3),
+	} {
+		if !reflect.DeepEqual(k, identity) {
+			t.Errorf("%s: expected: %v, actual: %v", name, identity, k)
+		}
+		if err := k.Validate(); err != nil {
+			t.Errorf("%s: expected: %v, actual: %v", name+" validate", nil, err)
+		}
+	}
+}
+
+func TestExecuteInvalidKernels(t *testing.T) {
+	mi := images["base-gray"]
+
+	filter := bilateral.New(mi, 16, 0.1)
+	filter.Kernels = []bilateral.Kernel{{Weights: []float64{math.NaN()}}}
+	if err := filter.Execute(); !errors.Is(err, bilateral.ErrInvalidOption) {
+		t.Errorf("%s: expected: %v, actual: %v", "Kernels", bilateral.ErrInvalidOption, err)
+	}
+
+	filter = bilateral.New(mi, 16, 0.1)
+	filter.RangeKernel = bilateral.Kernel{Weights: []float64{0, 0}}
+	if err := filter.Execute(); !errors.Is(err, bilateral.ErrInvalidOption) {
+		t.Errorf("%s: expected: %v, actual: %v", "RangeKernel", bilateral.ErrInvalidOption, err)
+	}
+}
+
+func TestFastBilateralRangeKernel(t *testing.T) {
+	// Two flat halves, 3.5 range sigmas apart
+	m := image.NewGray(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			m.SetGray(x, y, color.Gray{Y: 51})
+			if x >= 8 {
+				m.SetGray(x, y, color.Gray{Y: 140})
+			}
+		}
+	}
+
+	tukey := bilateral.New(m, 4, 0.1)
+	tukey.RangeKernel = bilateral.TukeyKernel(2)
+	tukey.Execute()
+	if c := tukey.At(7, 4).(color.RGBA); c.R != 51 {
+		t.Errorf("%s: expected: %d, actual: %d", "Tukey", 51, c.R)
+	}
+
+	lorentzian := bilateral.New(m, 4, 0.1)
+	lorentzian.RangeKernel = bilateral.LorentzianKernel(1, 6)
+	lorentzian.Execute()
+	if c := lorentzian.At(7, 4).(color.RGBA); c.R <= 51 {
+		t.Errorf("%s: expected greater than %d, actual: %d", "Lorentzian", 51, c.R)
+	}
+
+	// Kernels take precedence over RangeKernel
+	binomial := bilateral.New(m, 4, 0.1)
+	binomial.Kernels = []bilateral.Kernel{{}, {}, bilateral.BinomialKernel()}
+	binomial.RangeKernel = bilateral.LorentzianKernel(1, 6)
+	binomial.Execute()
+	filter := bilateral.New(m, 4, 0.1)
+	filter.Execute()
+	if !reflect.DeepEqual(binomial.ResultImage(), filter.ResultImage()) {
+		t.Errorf("%s: expected: %s, actual: %s", "Kernels", "default result", "RangeKernel result")
+	}
+}
+
+func TestFastBilateralKernels(t *testing.T) {
+	mi := images["base-gray"]
+	mo := images["base-gray-filtered"]
+
+	filter := bilateral.Auto(mi)
+	filter.Kernels = []bilateral.Kernel{bilateral.BinomialKernel(), {}, bilateral.BinomialKernel()}
+	filter.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "ResultImage", mo, filter.ResultImage())
+	}
+
+	for _, k := range []bilateral.Kernel{bilateral.GaussianKernel(1, 1), bilateral.BoxKernel(1, 2)} {
+		filter := bilateral.Auto(mi)
+		filter.Kernels = []bilateral.Kernel{k, k, k}
+		if err := filter.Execute(); err != nil {
+			t.Errorf("%s: expected: %v, actual: %v", "Execute", nil, err)
+		}
+		if !filter.Bounds().Eq(filter.ResultImage().Bounds()) {
+			t.Errorf("%s: expected: %v, actual: %v", "Bounds", filter.Bounds(), filter.ResultImage().Bounds())
+		}
+	}
+}
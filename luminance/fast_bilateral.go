@@ -1,17 +1,19 @@
 package luminance
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"math"
 	"sync"
 
 	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/internal/util"
 	"gonum.org/v1/gonum/mat"
 )
 
 const (
-	maxrange  = 65535
 	dimension = 3
 	// padding space
 	paddingS = 2
@@ -26,9 +28,50 @@ type FastBilateral struct {
 	Image      image.Image
 	SigmaRange float64
 	SigmaSpace float64
+	// Guide, when set, is the image whose luminance defines the range coordinates (joint bilateral filtering).
+	// The filtered luminance is still taken from Image. It must cover the Image's bounds.
+	Guide image.Image
+	// Mask, when set, restricts the filtering to the pixels where its alpha is not zero.
+	// Only those pixels contribute to the grid, the others are copied through.
+	// Partial alphas blend the filtered and the original luminances.
+	Mask image.Image
+	// Confidence, when set, scales the contribution of each pixel to the grid by its gray level clamped to [0, 1]
+	// (normalized convolution). Pixels with a zero confidence are filled in from their neighbours
+	// with similar luminances, so a Guide should be used when their values are invalid.
+	Confidence image.Image
+	// SigmaSpaceY, when positive, is the spatial sigma along the vertical axis
+	// and SigmaSpace is only used along the horizontal axis.
+	SigmaSpaceY float64
+	// Rotation is the angle in radians between the image axes and the spatial sigmas' axes.
+	Rotation float64
+	// Kernels holds the blur kernel of each grid axis (x, y, then the luminance).
+	// Missing or zero kernels fall back to bilateral.BinomialKernel.
+	Kernels []bilateral.Kernel
+	// RangeKernel, when set, is the kernel of the luminance axis if missing from Kernels,
+	// e.g. a robust bilateral.TukeyKernel, bilateral.LorentzianKernel or bilateral.HuberKernel instead of the implicit Gaussian.
+	RangeKernel bilateral.Kernel
+	// Splatting defines how the pixels are accumulated into the grid, bilateral.Nearest by default.
+	Splatting bilateral.Splatting
+	// Interpolation defines how the grid is sliced, bilateral.Linear by default.
+	Interpolation bilateral.Interpolation
+	// OnProgress, when set, is called with the progress of Execute's phases and of the slicing.
+	OnProgress bilateral.ProgressFunc
+	// Percentiles, when enabled, computes the luminance bounds from the given percentiles
+	// instead of the absolute min and max. Outliers are clamped into the edge bins.
+	Percentiles bilateral.Percentiles
+	// MaxMemory, when positive, caps the memory in bytes allocated by the grids (see Footprint).
+	MaxMemory int64
+	// Coarsen makes Execute coarsen the grid sampling until it fits MaxMemory,
+	// instead of returning bilateral.ErrMemoryLimit.
+	Coarsen    bool
 	minmaxOnce sync.Once
-	min        float64
-	max        float64
+	// Nearest contributing pixel of each pixel, computed on the first empty slice.
+	nearestOnce sync.Once
+	nearestPix  []int
+	min         float64
+	max         float64
+	// Grid cells skipped between the image bounds' min and the spatial lattice.
+	shift [2]float64
 	// size:
 	// 0 -> smallWidth
 	// 1 -> smallHeight
@@ -38,6 +81,17 @@ type FastBilateral struct {
 	auto bool
 }
 
+var _ bilateral.Filter = (*FastBilateral)(nil)
+
+func init() {
+	bilateral.Register("luminance", func(m image.Image, sigmaSpace, sigmaRange float64) bilateral.Filter {
+		if sigmaSpace <= 0 || sigmaRange <= 0 {
+			return Auto(m)
+		}
+		return New(m, sigmaSpace, sigmaRange)
+	})
+}
+
 // Auto instanciates a new FastBilateral with automatic sigma values.
 func Auto(m image.Image) *FastBilateral {
 	f := New(m, 16, 0.1)
@@ -58,11 +112,32 @@ func New(m image.Image, sigmaSpace, sigmaRange float64) *FastBilateral {
 }
 
 // Execute runs the bilateral filter.
-func (f *FastBilateral) Execute() {
+// It returns an error wrapping bilateral.ErrInvalidOption if the sigma values are not positive and finite
+// or if a kernel is invalid, or wrapping bilateral.ErrMemoryLimit if the grids do not fit MaxMemory.
+func (f *FastBilateral) Execute() error {
+	if !positive(f.SigmaSpace) {
+		return fmt.Errorf("%w: sigma space must be positive and finite, got %v", bilateral.ErrInvalidOption, f.SigmaSpace)
+	}
+	if !f.auto && !positive(f.SigmaRange) {
+		return fmt.Errorf("%w: sigma range must be positive and finite, got %v", bilateral.ErrInvalidOption, f.SigmaRange)
+	}
+	for axis, k := range f.Kernels {
+		if err := k.Validate(); err != nil {
+			return fmt.Errorf("%w: kernel of axis %d: %v", bilateral.ErrInvalidOption, axis, err)
+		}
+	}
+	if err := f.RangeKernel.Validate(); err != nil {
+		return fmt.Errorf("%w: range kernel: %v", bilateral.ErrInvalidOption, err)
+	}
+
 	f.minmaxOnce.Do(f.minmax)
+	if err := f.fit(); err != nil {
+		return err
+	}
 	f.downsampling()
 	f.convolution()
 	f.normalize()
+	return nil
 }
 
 // ColorModel returns the Image's color model.
@@ -77,21 +152,21 @@ func (f *FastBilateral) Bounds() image.Rectangle {
 
 // At computes the interpolation and returns the filtered color at the given coordinates.
 func (f *FastBilateral) At(x, y int) color.Color {
+	if f.coverage(x, y) == 0 {
+		return color.RGBAModel.Convert(f.Image.At(x, y)) // Copied through
+	}
+
 	r, g, b, a := f.Image.At(x, y).RGBA()
-	X, Y, Z := colorful.LinearRgbToXyz(f.color(r), f.color(g), f.color(b))
+	X, Y, Z := colorful.LinearRgbToXyz(util.Color(r), util.Color(g), util.Color(b))
 
-	// Grid coords
-	gw := float64(x)/f.SigmaSpace + paddingS // Grid width
-	gh := float64(y)/f.SigmaSpace + paddingS // Grid height
-	gc := (Y-f.min)/f.SigmaRange + paddingR  // Grid luminance
-	Y2 := f.trilinearInterpolation(gw, gh, gc)
+	Y2 := f.filtered(x, y, Y)
 
 	delta := Y - Y2
 	R, G, B := colorful.XyzToLinearRgb(X-delta, Y2, Z-delta)
 	return color.RGBA{
-		R: uint8(f.clamp(0, 255, int(R*255))),
-		G: uint8(f.clamp(0, 255, int(G*255))),
-		B: uint8(f.clamp(0, 255, int(B*255))),
+		R: uint8(util.Clamp(0, 255, int(R*255))),
+		G: uint8(util.Clamp(0, 255, int(G*255))),
+		B: uint8(util.Clamp(0, 255, int(B*255))),
 		A: uint8(a),
 	}
 }
@@ -100,76 +175,271 @@ func (f *FastBilateral) At(x, y int) color.Color {
 func (f *FastBilateral) ResultImage() image.Image {
 	d := f.Image.Bounds()
 	dst := image.NewRGBA(d)
-	for x := 0; x < d.Dx(); x++ {
-		for y := 0; y < d.Dy(); y++ {
+	step := f.OnProgress.Counter(bilateral.PhaseSlicing, d.Dx())
+	for x := d.Min.X; x < d.Max.X; x++ {
+		for y := d.Min.Y; y < d.Max.Y; y++ {
 			dst.Set(x, y, f.At(x, y))
 		}
+		step()
 	}
 	return dst
 }
 
-func (f *FastBilateral) minmax() {
+// ResultFloat computes the interpolation and returns the filtered image without quantization.
+// bilateral.FloatImage sources are read without quantization either.
+func (f *FastBilateral) ResultFloat() *bilateral.FloatImage {
 	d := f.Image.Bounds()
-	for y := 0; y < d.Dy(); y++ {
-		for x := 0; x < d.Dx(); x++ {
-			r, g, b, _ := f.Image.At(x, y).RGBA()
-			_, Y, _ := colorful.LinearRgbToXyz(f.color(r), f.color(g), f.color(b))
-			f.min = math.Min(f.min, Y)
-			f.max = math.Max(f.max, Y)
+	dst := bilateral.NewFloatImage(d)
+	step := f.OnProgress.Counter(bilateral.PhaseSlicing, d.Dy())
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			r, g, b, a := rgba(f.Image, x, y)
+			if f.coverage(x, y) == 0 {
+				dst.SetFloat(x, y, r, g, b, a) // Copied through
+				continue
+			}
+
+			X, Y, Z := colorful.LinearRgbToXyz(r, g, b)
+			Y2 := f.filtered(x, y, Y)
+
+			delta := Y - Y2
+			R, G, B := colorful.XyzToLinearRgb(X-delta, Y2, Z-delta)
+			dst.SetFloat(x, y, R, G, B, a)
 		}
+		step()
 	}
+	return dst
+}
 
-	if f.auto {
-		f.SigmaRange = (f.max - f.min) * 0.1
+// ResultMap computes the interpolation and returns the filtered luminance, without quantization.
+func (f *FastBilateral) ResultMap() *bilateral.FloatMap {
+	d := f.Image.Bounds()
+	dst := bilateral.NewFloatMap(d)
+	step := f.OnProgress.Counter(bilateral.PhaseSlicing, d.Dy())
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			dst.SetFloat(x, y, float32(f.filtered(x, y, f.luminance(f.Image, x, y))))
+		}
+		step()
 	}
+	return dst
+}
 
-	f.size[0] = int(float64(d.Dx()-1)/f.SigmaSpace) + 1 + 2*paddingS
-	f.size[1] = int(float64(d.Dy()-1)/f.SigmaSpace) + 1 + 2*paddingS
-	f.size[2] = int((f.max-f.min)/f.SigmaRange) + 1 + 2*paddingR
+// filtered computes the interpolation and returns the filtered luminance of the pixel (x, y) whose luminance is Y.
+func (f *FastBilateral) filtered(x, y int, Y float64) float64 {
+	coverage := f.coverage(x, y)
+	if coverage == 0 {
+		return Y // Copied through
+	}
+
+	key := Y
+	if f.Guide != nil {
+		key = f.luminance(f.Guide, x, y)
+	}
+
+	// Grid coords
+	gw, gh := f.spaceCoord(x, y)
+	gc := f.rangeCoord(key) + paddingR // Grid luminance
+	if f.empty(gw+paddingS, gh+paddingS, gc) {
+		// No pixel contributes around (x, y), e.g. a hole of zero confidence wider than the blur
+		return f.fill(x, y, Y, coverage)
+	}
+	Y2 := f.slice(gw+paddingS, gh+paddingS, gc)
+	if coverage < 1 {
+		Y2 = coverage*Y2 + (1-coverage)*Y
+	}
+	return Y2
+}
+
+func (f *FastBilateral) minmax() {
+	d := f.Image.Bounds()
+	m := f.Image
+	if f.Guide != nil {
+		m = f.Guide // Range coordinates' source
+	}
+
+	passes := 1
+	if f.Percentiles.Enabled() {
+		passes = 2 // The second pass counts the luminances in a histogram spanning the absolute bounds
+	}
+	step := f.OnProgress.Counter(bilateral.PhaseMinmax, passes*d.Dy())
+	f.scan(m, step, func(Y float64) {
+		f.min = math.Min(f.min, Y)
+		f.max = math.Max(f.max, Y)
+	})
+
+	if f.Percentiles.Enabled() && f.min < f.max {
+		histogram := bilateral.NewHistogram(f.min, f.max, util.MaxRange+1)
+		f.scan(m, step, histogram.Add)
+
+		// Keep the bounds inside the measured luminances
+		f.min = math.Max(f.min, histogram.Percentile(f.Percentiles.Low))
+		f.max = math.Min(f.max, histogram.Percentile(f.Percentiles.High))
+	}
+
+	if f.min > f.max { // Nothing scanned (e.g. empty mask)
+		f.min, f.max = 0, 0
+	}
+
+	if f.auto {
+		f.SigmaRange = math.Max((f.max-f.min)*0.1, minSigmaRange) // Floored for flat images
+	}
 
 	// fmt.Println("ssp:", f.SigmaSpace, " - sra:", f.SigmaRange)
 	// fmt.Println("min:", f.min, "- max:", f.max)
+}
+
+// scan calls fn with the luminance of each contributing pixel of m, and step after each row.
+func (f *FastBilateral) scan(m image.Image, step func(), fn func(Y float64)) {
+	d := f.Image.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			if f.weight(x, y) == 0 {
+				continue
+			}
+			fn(f.luminance(m, x, y))
+		}
+		step()
+	}
+}
+
+// resize computes the grid size from the sigma values.
+func (f *FastBilateral) resize() {
+	d := f.Image.Bounds()
+	corners := [4]image.Point{d.Min, {d.Max.X - 1, d.Min.Y}, {d.Min.X, d.Max.Y - 1}, d.Max.Sub(image.Pt(1, 1))}
+	min := [2]float64{math.Inf(1), math.Inf(1)}
+	max := [2]float64{math.Inf(-1), math.Inf(-1)}
+	for _, p := range corners {
+		u, v := f.project(p.X, p.Y)
+		min[0], max[0] = math.Min(min[0], u), math.Max(max[0], u)
+		min[1], max[1] = math.Min(min[1], v), math.Max(max[1], v)
+	}
+	f.shift[0] = math.Floor(min[0])
+	f.shift[1] = math.Floor(min[1])
+
+	f.size[0] = int(max[0]-f.shift[0]) + 1 + 2*paddingS
+	f.size[1] = int(max[1]-f.shift[1]) + 1 + 2*paddingS
+	f.size[2] = int((f.max-f.min)/f.SigmaRange) + 1 + 2*paddingR
+
 	// fmt.Println("size:", f.mul(f.size...), f.size)
 }
 
+// sigmaSpaceY returns the spatial sigma along the vertical axis.
+func (f *FastBilateral) sigmaSpaceY() float64 {
+	if f.SigmaSpaceY > 0 {
+		return f.SigmaSpaceY
+	}
+	return f.SigmaSpace
+}
+
+// project returns the position of the pixel (x, y) on the spatial lattice, in sigma units.
+func (f *FastBilateral) project(x, y int) (float64, float64) {
+	o := f.Image.Bounds().Min
+	dx, dy := float64(x-o.X), float64(y-o.Y)
+	if f.Rotation != 0 {
+		sin, cos := math.Sincos(f.Rotation)
+		dx, dy = dx*cos+dy*sin, dy*cos-dx*sin
+	}
+	return dx / f.SigmaSpace, dy / f.sigmaSpaceY()
+}
+
+// spaceCoord returns the unpadded grid coordinates of the pixel (x, y).
+func (f *FastBilateral) spaceCoord(x, y int) (float64, float64) {
+	u, v := f.project(x, y)
+	return u - f.shift[0], v - f.shift[1]
+}
+
+// rangeCoord returns the unpadded grid coordinate of the luminance Y.
+// Luminances out of the bounds are clamped into the edge bins.
+func (f *FastBilateral) rangeCoord(Y float64) float64 {
+	return (math.Min(math.Max(Y, f.min), f.max) - f.min) / f.SigmaRange
+}
+
 func (f *FastBilateral) downsampling() {
 	d := f.Image.Bounds()
 	offset := make([]int, dimension)
 
-	size := f.mul(f.size...)
+	size := util.Mul(f.size...)
 	dim := dimension - 1 // # 1 luminance and 1 threshold (edge weight)
 	f.grid = mat.NewDense(size, dim, make([]float64, dim*size))
 
-	for x := 0; x < d.Dx(); x++ {
-		offset[0] = int(float64(x)/f.SigmaSpace+0.5) + paddingS
+	step := f.OnProgress.Counter(bilateral.PhaseDownsampling, d.Dx())
+	for x := d.Min.X; x < d.Max.X; x++ {
+		for y := d.Min.Y; y < d.Max.Y; y++ {
+			gw, gh := f.spaceCoord(x, y)
 
-		for y := 0; y < d.Dy(); y++ {
-			offset[1] = int(float64(y)/f.SigmaSpace+0.5) + paddingS
+			w := f.weight(x, y)
+			if w == 0 {
+				continue
+			}
+			Y := f.luminance(f.Image, x, y)
+			key := Y
+			if f.Guide != nil {
+				key = f.luminance(f.Guide, x, y)
+			}
 
-			r, g, b, _ := f.Image.At(x, y).RGBA()
-			_, Y, _ := colorful.LinearRgbToXyz(f.color(r), f.color(g), f.color(b))
+			if f.Splatting == bilateral.Tent {
+				f.tentSplat(gw+paddingS, gh+paddingS, f.rangeCoord(key)+paddingR, Y, w)
+				continue
+			}
 
-			offset[2] = int((Y-f.min)/f.SigmaRange+0.5) + paddingR
+			offset[0] = int(gw+0.5) + paddingS
+			offset[1] = int(gh+0.5) + paddingS
+			offset[2] = int(f.rangeCoord(key)+0.5) + paddingR
 
 			i := f.offset(offset...)
 			v := f.grid.RawRowView(i)
-			v[0] += Y // luminance
-			v[1]++    // threshold
+			v[0] += w * Y // luminance
+			v[1] += w     // threshold
 			f.grid.SetRow(i, v)
 		}
+		step()
+	}
+}
+
+// tentSplat accumulates the luminance Y weighted by w into the 8 cells surrounding the given grid coordinates.
+func (f *FastBilateral) tentSplat(gx, gy, gz float64, Y, w float64) {
+	index := []int{int(gx), int(gy), int(gz)}
+	alpha := []float64{gx - float64(index[0]), gy - float64(index[1]), gz - float64(index[2])}
+
+	off := make([]int, dimension)
+	for i := 0; i < 1<<dimension; i++ {
+		weight := w
+		for n := range off {
+			if i&(1<<uint(n)) == 0 {
+				off[n] = index[n]
+				weight *= 1.0 - alpha[n]
+			} else {
+				off[n] = util.Clamp(0, f.size[n]-1, index[n]+1)
+				weight *= alpha[n]
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+
+		v := f.grid.RawRowView(f.offset(off...))
+		v[0] += weight * Y // luminance
+		v[1] += weight     // threshold
 	}
 }
 
 func (f *FastBilateral) convolution() {
-	size := f.mul(f.size...)
+	size := util.Mul(f.size...)
 	dim := dimension - 1 // # luminance and 1 threshold (edge weight)
 	buffer := mat.NewDense(size, dim, make([]float64, dim*size))
+	sum := mat.NewVecDense(dim, nil)
 
-	for dim := 0; dim < dimension; dim++ { // x, y, and luminance
-		off := make([]int, dimension)
-		off[dim] = 1 // Wanted dimension offset
+	passes := 0
+	for axis := 0; axis < dimension; axis++ {
+		passes += f.kernel(axis).Iterations()
+	}
+	pass := 0
+
+	for axis := 0; axis < dimension; axis++ { // x, y, and luminance
+		k := f.kernel(axis)
 
-		for n := 0; n < 2; n++ { // itterations (pass?)
+		for n := 0; n < k.Iterations(); n++ { // passes
 			f.grid, buffer = buffer, f.grid
 
 			for x := 1; x < f.size[0]-1; x++ {
@@ -177,20 +447,60 @@ func (f *FastBilateral) convolution() {
 
 					for z := 1; z < f.size[2]-1; z++ {
 						vg := f.grid.RowView(f.offset(x, y, z)).(*mat.VecDense)
-						prev := buffer.RowView(f.offset(x-off[0], y-off[1], z-off[2])).(*mat.VecDense)
-						curr := buffer.RowView(f.offset(x, y, z)).(*mat.VecDense)
-						next := buffer.RowView(f.offset(x+off[0], y+off[1], z+off[2])).(*mat.VecDense)
-
-						// (prev + 2.0 * curr + next) / 4.0
-						vg.AddVec(prev, next)
-						vg.AddScaledVec(vg, 2, curr)
-						vg.ScaleVec(0.25, vg)
+						vg.ScaleVec(k.Weights[0], buffer.RowView(f.offset(x, y, z)))
+
+						// weight * (prev + next), out of grid taps are zeros
+						for i := 1; i <= k.Radius(); i++ {
+							prev := f.neighbour(buffer, axis, -i, x, y, z)
+							next := f.neighbour(buffer, axis, i, x, y, z)
+
+							switch {
+							case prev != nil && next != nil:
+								sum.AddVec(prev, next)
+								vg.AddScaledVec(vg, k.Weights[i], sum)
+							case prev != nil:
+								vg.AddScaledVec(vg, k.Weights[i], prev)
+							case next != nil:
+								vg.AddScaledVec(vg, k.Weights[i], next)
+							}
+						}
 					}
 				}
 			}
+
+			pass++
+			if f.OnProgress != nil {
+				f.OnProgress(bilateral.Progress{
+					Phase:    bilateral.PhaseConvolution,
+					Pass:     pass,
+					Passes:   passes,
+					Fraction: float64(pass) / float64(passes),
+				})
+			}
 		}
 	}
-	return
+}
+
+// kernel returns the kernel of the given axis, bilateral.BinomialKernel by default.
+func (f *FastBilateral) kernel(axis int) bilateral.Kernel {
+	if axis < len(f.Kernels) && len(f.Kernels[axis].Weights) > 0 {
+		return f.Kernels[axis]
+	}
+	if axis == 2 && len(f.RangeKernel.Weights) > 0 {
+		return f.RangeKernel
+	}
+	return bilateral.BinomialKernel()
+}
+
+// neighbour returns the row at the given distance along the axis, or nil if it is out of the grid.
+func (f *FastBilateral) neighbour(m *mat.Dense, axis, distance int, offset ...int) mat.Vector {
+	n := make([]int, len(offset))
+	copy(n, offset)
+	n[axis] += distance
+	if n[axis] < 0 || n[axis] >= f.size[axis] {
+		return nil
+	}
+	return m.RowView(f.offset(n...))
 }
 
 func (f *FastBilateral) normalize() {
@@ -202,18 +512,94 @@ func (f *FastBilateral) normalize() {
 	}
 }
 
+// empty returns true if the grid cells surrounding the given coordinates have no contribution.
+func (f *FastBilateral) empty(gx, gy, gz float64) bool {
+	x := util.Clamp(0, f.size[0]-1, int(gx))
+	y := util.Clamp(0, f.size[1]-1, int(gy))
+	z := util.Clamp(0, f.size[2]-1, int(gz))
+	for _, xi := range []int{x, util.Clamp(0, f.size[0]-1, x+1)} {
+		for _, yi := range []int{y, util.Clamp(0, f.size[1]-1, y+1)} {
+			for _, zi := range []int{z, util.Clamp(0, f.size[2]-1, z+1)} {
+				if f.grid.At(f.offset(xi, yi, zi), 1) > 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// fill returns the filtered luminance of the nearest contributing pixel, blended by the coverage,
+// for the pixel (x, y) of luminance Y whose grid neighbourhood is empty.
+// Y is kept when no pixel contributes to the grid.
+func (f *FastBilateral) fill(x, y int, Y, coverage float64) float64 {
+	d := f.Image.Bounds()
+	f.nearestOnce.Do(func() {
+		f.nearestPix = util.Nearest(d, func(x, y int) bool {
+			return f.weight(x, y) > 0
+		})
+	})
+	if !(image.Point{x, y}.In(d)) {
+		return Y
+	}
+	i := f.nearestPix[(y-d.Min.Y)*d.Dx()+x-d.Min.X]
+	nx, ny := d.Min.X+i%d.Dx(), d.Min.Y+i/d.Dx()
+	if i < 0 || (nx == x && ny == y) {
+		return Y
+	}
+
+	filled := f.filtered(nx, ny, f.luminance(f.Image, nx, ny))
+	return coverage*filled + (1-coverage)*Y
+}
+
+// slice interpolates the grid at the given coordinates.
+func (f *FastBilateral) slice(gx, gy, gz float64) float64 {
+	if f.Interpolation == bilateral.Cubic {
+		return f.cubicInterpolation(gx, gy, gz)
+	}
+	return f.trilinearInterpolation(gx, gy, gz)
+}
+
+// cubicInterpolation performs a Catmull-Rom interpolation along x and y, and a linear one along the luminance.
+func (f *FastBilateral) cubicInterpolation(gx, gy, gz float64) float64 {
+	width := f.size[0]
+	height := f.size[1]
+	depth := f.size[2]
+
+	// Index
+	x := int(gx)
+	y := int(gy)
+	z := util.Clamp(0, depth-1, int(gz))
+	zz := util.Clamp(0, depth-1, z+1)
+
+	// Weights
+	wx := f.catmullRom(gx - float64(x))
+	wy := f.catmullRom(gy - float64(y))
+	za := gz - float64(z)
+
+	var v float64
+	for j, wj := range wy {
+		yj := util.Clamp(0, height-1, y-1+j)
+		for i, wi := range wx {
+			xi := util.Clamp(0, width-1, x-1+i)
+			v += wj * wi * ((1.0-za)*f.grid.At(f.offset(xi, yj, z), 0) + za*f.grid.At(f.offset(xi, yj, zz), 0))
+		}
+	}
+	return v
+}
+
 func (f *FastBilateral) trilinearInterpolation(gx, gy, gz float64) float64 {
 	width := f.size[0]
 	height := f.size[1]
 	depth := f.size[2]
 
 	// Index
-	x := f.clamp(0, width-1, int(gx))
-	xx := f.clamp(0, width-1, x+1)
-	y := f.clamp(0, height-1, int(gy))
-	yy := f.clamp(0, height-1, y+1)
-	z := f.clamp(0, depth-1, int(gz))
-	zz := f.clamp(0, depth-1, z+1)
+	x := util.Clamp(0, width-1, int(gx))
+	xx := util.Clamp(0, width-1, x+1)
+	y := util.Clamp(0, height-1, int(gy))
+	yy := util.Clamp(0, height-1, y+1)
+	z := util.Clamp(0, depth-1, int(gz))
+	zz := util.Clamp(0, depth-1, z+1)
 
 	// Alpha
 	xa := gx - float64(x)
@@ -231,33 +617,75 @@ func (f *FastBilateral) trilinearInterpolation(gx, gy, gz float64) float64 {
 		ya*xa*za*f.grid.At(f.offset(xx, yy, zz), 0)
 }
 
-func (f *FastBilateral) clamp(min, max, v int) int {
-	if v < min {
-		v = 0
-	}
-	if v > max {
-		v = max
-	}
-	return v
-}
-
-func (f *FastBilateral) mul(size ...int) (n int) {
-	n = 1
-	for _, v := range size {
-		n *= v
+// catmullRom returns the weights of the 4 taps surrounding the position t in [0, 1).
+func (f *FastBilateral) catmullRom(t float64) [4]float64 {
+	t2 := t * t
+	t3 := t2 * t
+	return [4]float64{
+		(-t3 + 2*t2 - t) / 2,
+		(3*t3 - 5*t2 + 2) / 2,
+		(-3*t3 + 4*t2 + t) / 2,
+		(t3 - t2) / 2,
 	}
-	return
 }
 
 // slice[x + WIDTH*y + WIDTH*HEIGHT*z)]
 func (f *FastBilateral) offset(size ...int) (n int) {
 	n = size[0] // x
 	for i, v := range size[1:] {
-		n += v * f.mul(f.size[0:i+1]...) // y, z
+		n += v * util.Mul(f.size[0:i+1]...) // y, z
 	}
 	return
 }
 
-func (f *FastBilateral) color(v uint32) float64 {
-	return float64(v) / maxrange
+// luminance returns the luminance of the pixel (x, y) of the given image.
+// bilateral.FloatMap values and signal samples are read without quantization nor conversion,
+// bilateral.FloatImage pixels without quantization.
+func (f *FastBilateral) luminance(m image.Image, x, y int) float64 {
+	switch fm := m.(type) {
+	case *bilateral.FloatMap:
+		return float64(fm.FloatAt(x, y))
+	case *samples:
+		return fm.values[x]
+	}
+
+	r, g, b, _ := rgba(m, x, y)
+	_, Y, _ := colorful.LinearRgbToXyz(r, g, b)
+	return Y
+}
+
+// rgba returns the channels of the pixel (x, y) of the given image in [0, 1].
+// bilateral.FloatImage pixels are read without quantization.
+func rgba(m image.Image, x, y int) (r, g, b, a float64) {
+	if fm, ok := m.(*bilateral.FloatImage); ok {
+		return fm.FloatAt(x, y)
+	}
+
+	r32, g32, b32, a32 := m.At(x, y).RGBA()
+	return util.Color(r32), util.Color(g32), util.Color(b32), util.Color(a32)
+}
+
+// coverage returns the mask's alpha at (x, y) in [0, 1], 1 without mask.
+func (f *FastBilateral) coverage(x, y int) float64 {
+	if f.Mask == nil {
+		return 1
+	}
+	_, _, _, a := f.Mask.At(x, y).RGBA()
+	return util.Color(a)
+}
+
+// weight returns the contribution of the pixel (x, y) to the grid, zero out of the mask.
+func (f *FastBilateral) weight(x, y int) float64 {
+	if f.coverage(x, y) == 0 {
+		return 0
+	}
+	if f.Confidence == nil {
+		return 1
+	}
+
+	w := f.luminance(f.Confidence, x, y)
+	if !(w > 0) { // Negative and NaN confidences do not contribute
+		return 0
+	}
+	return math.Min(w, 1)
 }
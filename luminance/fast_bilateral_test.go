@@ -1,11 +1,14 @@
 package luminance_test
 
 import (
+	"image"
 	"image/color"
 	_ "image/jpeg"
+	"math"
 	"reflect"
 	"testing"
 
+	"github.com/mdouchement/bilateral"
 	"github.com/mdouchement/bilateral/luminance"
 )
 
@@ -70,3 +73,241 @@ func TestFastBilateralGray(t *testing.T) {
 		}
 	}
 }
+
+// hotPixels returns an image stepping from 60 to 140 at x = 16,
+// with a few hot (255) and dead (0) pixels far out of the step's range.
+func hotPixels() *image.Gray {
+	m := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			m.SetGray(x, y, color.Gray{Y: 60})
+			if x >= 16 {
+				m.SetGray(x, y, color.Gray{Y: 140})
+			}
+		}
+	}
+	m.SetGray(4, 4, color.Gray{Y: 255})
+	m.SetGray(28, 28, color.Gray{Y: 255})
+	m.SetGray(28, 4, color.Gray{Y: 0})
+	return m
+}
+
+func TestFastBilateralPercentiles(t *testing.T) {
+	mi := hotPixels()
+
+	depth := func(p bilateral.Percentiles) (int, *luminance.FastBilateral) {
+		filter := luminance.New(mi, 4, 0.05)
+		filter.Percentiles = p
+		size := filter.Footprint().Size
+		filter.Execute()
+		return size[len(size)-1], filter
+	}
+	full, _ := depth(bilateral.Percentiles{Low: 0, High: 1})
+	clipped, filter := depth(bilateral.Percentiles{Low: 0.01, High: 0.99})
+
+	// The hot and dead pixels no longer stretch the range axis
+	if clipped >= full {
+		t.Errorf("%s: expected: < %d, actual: %d", "Depth", full, clipped)
+	}
+
+	m := filter.ResultImage()
+	for _, p := range []struct {
+		x, y     int
+		expected uint8
+	}{
+		{x: 8, y: 20, expected: 60},
+		{x: 12, y: 10, expected: 60},
+		{x: 20, y: 20, expected: 140},
+		{x: 24, y: 12, expected: 140},
+	} {
+		r, _, _, _ := m.At(p.x, p.y).RGBA()
+		if diff := int(r>>8) - int(p.expected); diff < -2 || diff > 2 {
+			t.Errorf("%s(%d, %d): expected: %d, actual: %d", "At", p.x, p.y, p.expected, r>>8)
+		}
+	}
+}
+
+func TestFastBilateralAnisotropic(t *testing.T) {
+	// Horizontal stripes
+	mi := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			mi.SetGray(x, y, color.Gray{Y: uint8(100 + 40*(y%2))})
+		}
+	}
+	contrast := func(m image.Image) int {
+		r1, _, _, _ := m.At(16, 16).RGBA()
+		r2, _, _, _ := m.At(16, 17).RGBA()
+		return int(r2>>8) - int(r1>>8)
+	}
+
+	isotropic := luminance.New(mi, 8, 0.5)
+	isotropic.Execute()
+
+	anisotropic := luminance.New(mi, 8, 0.5)
+	anisotropic.SigmaSpaceY = 0.2
+	anisotropic.Execute()
+
+	if c := contrast(isotropic.ResultImage()); c > 10 {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Isotropic contrast", 10, c)
+	}
+	if c := contrast(anisotropic.ResultImage()); c < 30 {
+		t.Errorf("%s: expected greater than %d, actual: %d", "Anisotropic contrast", 30, c)
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	mi := images["base"]
+	mo := images["filtered"]
+
+	filter, err := bilateral.NewFilter("luminance", mi, 0, 0)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewFilter", nil, err)
+	}
+	filter.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "ResultImage", mo, filter.ResultImage())
+	}
+}
+
+func TestFastBilateralResultFloat(t *testing.T) {
+	mi := images["base"]
+	mo := images["filtered"]
+
+	filter := luminance.Auto(mi)
+	filter.Execute()
+
+	m := filter.ResultFloat()
+	for y := 0; y < mi.Bounds().Dy(); y++ {
+		for x := 0; x < mi.Bounds().Dx(); x++ {
+			r, g, b, _ := m.FloatAt(x, y)
+			c := mo.RGBAAt(x, y)
+			for i, v := range []float64{r, g, b} {
+				expected := float64([]uint8{c.R, c.G, c.B}[i]) / 255
+				if v = math.Min(math.Max(v, 0), 1); v < expected || v > expected+1.0/255 {
+					t.Fatalf("%s(%d,%d)[%d]: expected: %f, actual: %f", "ResultFloat", x, y, i, expected, v)
+				}
+			}
+		}
+	}
+
+	// Float images are filtered without quantization
+	float := luminance.Auto(bilateral.ToFloatImage(mi))
+	float.Execute()
+	if !reflect.DeepEqual(float.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "FloatImage", mo, float.ResultImage())
+	}
+}
+
+func TestFastBilateralProgress(t *testing.T) {
+	last := map[bilateral.Phase]bilateral.Progress{}
+	filter := luminance.Auto(images["base"])
+	filter.OnProgress = func(p bilateral.Progress) {
+		last[p.Phase] = p
+	}
+	filter.Execute()
+	filter.ResultFloat()
+
+	for _, phase := range []bilateral.Phase{bilateral.PhaseMinmax, bilateral.PhaseDownsampling, bilateral.PhaseConvolution, bilateral.PhaseSlicing} {
+		if last[phase].Fraction != 1 {
+			t.Errorf("%s: expected: %f, actual: %f", phase, 1.0, last[phase].Fraction)
+		}
+	}
+	if p := last[bilateral.PhaseConvolution]; p.Pass != 6 || p.Passes != 6 {
+		t.Errorf("%s: expected: %d/%d, actual: %d/%d", "Passes", 6, 6, p.Pass, p.Passes)
+	}
+}
+
+func TestFastBilateralKernels(t *testing.T) {
+	mi := images["base"]
+	mo := images["filtered"]
+
+	filter := luminance.Auto(mi)
+	filter.Kernels = []bilateral.Kernel{bilateral.BinomialKernel(), {}, bilateral.BinomialKernel()}
+	filter.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "ResultImage", mo, filter.ResultImage())
+	}
+
+	k := bilateral.GaussianKernel(2, 1)
+	filter = luminance.Auto(mi)
+	filter.Kernels = []bilateral.Kernel{k, k, k}
+	if err := filter.Execute(); err != nil {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+}
+
+func TestFastBilateralRangeKernel(t *testing.T) {
+	// Two flat halves, 3.5 range sigmas apart
+	m := image.NewGray(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			m.SetGray(x, y, color.Gray{Y: 51})
+			if x >= 8 {
+				m.SetGray(x, y, color.Gray{Y: 140})
+			}
+		}
+	}
+
+	tukey := luminance.New(m, 4, 0.1)
+	tukey.RangeKernel = bilateral.TukeyKernel(2)
+	tukey.Execute()
+
+	lorentzian := luminance.New(m, 4, 0.1)
+	lorentzian.RangeKernel = bilateral.LorentzianKernel(1, 6)
+	lorentzian.Execute()
+
+	if t7, l7 := tukey.At(7, 4).(color.RGBA).R, lorentzian.At(7, 4).(color.RGBA).R; l7 <= t7 {
+		t.Errorf("%s: expected greater than %d, actual: %d", "Lorentzian", t7, l7)
+	}
+}
+
+func TestFastBilateralCubic(t *testing.T) {
+	mi := images["base"]
+	mo := images["filtered"]
+
+	filter := luminance.Auto(mi)
+	filter.Interpolation = bilateral.Cubic
+	filter.Execute()
+
+	m := filter.ResultImage().(*image.RGBA)
+	for i := range m.Pix {
+		if diff := int(m.Pix[i]) - int(mo.Pix[i]); diff < -16 || diff > 16 {
+			t.Fatalf("%s[%d]: expected: %d, actual: %d", "Pix", i, mo.Pix[i], m.Pix[i])
+		}
+	}
+}
+
+func TestFastBilateralTent(t *testing.T) {
+	// Smooth gradient, left untouched by an ideal filter
+	gradient := func(x int) float64 { return 0.3 + 0.3*float64(x)/96 }
+	mi := image.NewGray16(image.Rect(0, 0, 96, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 96; x++ {
+			mi.SetGray16(x, y, color.Gray16{Y: uint16(gradient(x) * 65535)})
+		}
+	}
+
+	// Mean absolute error along a row, away from the image borders
+	banding := func(m image.Image) float64 {
+		var e float64
+		for x := 16; x < 80; x++ {
+			r, _, _, _ := m.At(x, 4).RGBA()
+			e += math.Abs(float64(r>>8) - gradient(x)*255)
+		}
+		return e / 64
+	}
+
+	nearest := luminance.New(mi, 6, 0.05)
+	nearest.Execute()
+
+	tent := luminance.New(mi, 6, 0.05)
+	tent.Splatting = bilateral.Tent
+	tent.Execute()
+
+	if n, te := banding(nearest.ResultImage()), banding(tent.ResultImage()); te >= n {
+		t.Errorf("%s: expected lower than %f, actual: %f", "Tent banding", n, te)
+	}
+}
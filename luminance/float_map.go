@@ -0,0 +1,25 @@
+package luminance
+
+import "github.com/mdouchement/bilateral"
+
+// FilterFloat32 runs the bilateral filter on a single-channel map of the given size, whose rows are stride values apart.
+// The optional guide, with the same layout, defines the edges to preserve (joint bilateral filtering).
+// The returned map's rows are width values apart.
+// It returns bilateral.ErrMapSize if a map is too short.
+func FilterFloat32(pix []float32, width, height, stride int, guide []float32, sigmaSpace, sigmaRange float64) ([]float32, error) {
+	m, err := bilateral.WrapFloatMap(pix, width, height, stride)
+	if err != nil {
+		return nil, err
+	}
+
+	f := New(m, sigmaSpace, sigmaRange)
+	if guide != nil {
+		if f.Guide, err = bilateral.WrapFloatMap(guide, width, height, stride); err != nil {
+			return nil, err
+		}
+	}
+	if err = f.Execute(); err != nil {
+		return nil, err
+	}
+	return f.ResultMap().Pix, nil
+}
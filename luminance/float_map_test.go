@@ -0,0 +1,104 @@
+package luminance_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/luminance"
+)
+
+// depth returns a noisy depth map in meters, with a step at x = 16 and rows padded to stride.
+func depth(stride int) []float32 {
+	pix := make([]float32, 31*stride+32)
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := float32(2)
+			if x >= 16 {
+				v = 8
+			}
+			pix[y*stride+x] = v + 0.1*float32((x*7+y*13)%5-2)
+		}
+	}
+	return pix
+}
+
+func TestFilterFloat32(t *testing.T) {
+	filtered, err := luminance.FilterFloat32(depth(40), 32, 32, 40, nil, 4, 1)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "FilterFloat32", nil, err)
+	}
+	if len(filtered) != 32*32 {
+		t.Fatalf("%s: expected: %v, actual: %v", "len", 32*32, len(filtered))
+	}
+
+	for _, p := range []struct {
+		x, y     int
+		expected float64
+	}{{4, 4, 2}, {13, 20, 2}, {18, 20, 8}, {28, 4, 8}} {
+		if v := filtered[p.y*32+p.x]; math.Abs(float64(v)-p.expected) > 0.1 {
+			t.Errorf("%s(%d,%d): expected: %v, actual: %v", "FilterFloat32", p.x, p.y, p.expected, v)
+		}
+	}
+
+	if _, err = luminance.FilterFloat32(depth(40), 32, 32, 40, make([]float32, 10), 4, 1); err != bilateral.ErrMapSize {
+		t.Errorf("%s: expected: %v, actual: %v", "Guide", bilateral.ErrMapSize, err)
+	}
+}
+
+func TestFilterFloat32Guide(t *testing.T) {
+	// A flat map guided by an edge keeps the edge's sides apart
+	pix := make([]float32, 32*32)
+	guide := depth(32)
+	for i := range pix {
+		pix[i] = guide[i]
+		guide[i] = float32(math.Round(float64(guide[i])))
+	}
+
+	filtered, err := luminance.FilterFloat32(pix, 32, 32, 32, guide, 4, 1)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "FilterFloat32", nil, err)
+	}
+	if v := filtered[20*32+15]; math.Abs(float64(v)-2) > 0.1 {
+		t.Errorf("%s: expected: %v, actual: %v", "FilterFloat32", 2, v)
+	}
+	if v := filtered[20*32+16]; math.Abs(float64(v)-8) > 0.1 {
+		t.Errorf("%s: expected: %v, actual: %v", "FilterFloat32", 8, v)
+	}
+}
+
+func TestFastBilateralPercentilesFloatMap(t *testing.T) {
+	// Depth map stepping from 10 to 30, with two far outliers
+	m := bilateral.NewFloatMap(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			m.SetFloat(x, y, 10)
+			if x >= 16 {
+				m.SetFloat(x, y, 30)
+			}
+		}
+	}
+	m.SetFloat(4, 4, 1000)
+	m.SetFloat(28, 28, 1000)
+
+	filter := luminance.New(m, 4, 1)
+	filter.Percentiles = bilateral.Percentiles{Low: 0.01, High: 0.99}
+	// Range bounds [10, 30]: 21 range cells and the padding
+	if depth := filter.Footprint().Size[2]; depth < 24 || depth > 25 {
+		t.Errorf("%s: expected: %v, actual: %v", "Depth", "24 or 25", depth)
+	}
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	result := filter.ResultMap()
+	for _, p := range []struct {
+		x, y     int
+		expected float64
+	}{{12, 16, 10}, {15, 16, 10}, {16, 16, 30}, {20, 16, 30}} {
+		if v := float64(result.FloatAt(p.x, p.y)); math.Abs(v-p.expected) > 0.1 {
+			t.Errorf("%s(%d,%d): expected: %v, actual: %v", "ResultMap", p.x, p.y, p.expected, v)
+		}
+	}
+}
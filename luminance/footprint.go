@@ -0,0 +1,57 @@
+package luminance
+
+import (
+	"fmt"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+const (
+	// Allocated bytes per grid cell: luminance and threshold.
+	cellBytes = 2 * 8
+	// Grids allocated during Execute (grid and convolution buffer).
+	grids = 2
+	// Sigmas' growth factor at each coarsening step.
+	coarsenFactor = 1.25
+	// Upper bound of coarsening steps (~2^24 growth).
+	coarsenSteps = 75
+)
+
+// Footprint predicts the grid dimensions and the memory allocated by Execute for the current parameters.
+// It scans the image to compute the luminance bounds, the scan is not repeated by Execute.
+func (f *FastBilateral) Footprint() bilateral.Footprint {
+	f.minmaxOnce.Do(f.minmax)
+	f.resize()
+
+	size := make([]int, len(f.size))
+	copy(size, f.size)
+	cells := util.Mul(size...)
+	return bilateral.Footprint{
+		Size:  size,
+		Cells: cells,
+		Bytes: int64(grids) * int64(cells) * cellBytes,
+	}
+}
+
+// fit computes the grid size and ensures it fits MaxMemory, coarsening the sampling if allowed.
+func (f *FastBilateral) fit() error {
+	fp := f.Footprint()
+	if f.MaxMemory <= 0 || fp.Bytes <= f.MaxMemory {
+		return nil
+	}
+
+	if f.Coarsen {
+		for i := 0; i < coarsenSteps && fp.Bytes > f.MaxMemory; i++ {
+			f.SigmaSpace *= coarsenFactor
+			f.SigmaSpaceY *= coarsenFactor
+			f.SigmaRange *= coarsenFactor
+			fp = f.Footprint()
+		}
+		if fp.Bytes <= f.MaxMemory {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: grid %v needs %d bytes, %d allowed", bilateral.ErrMemoryLimit, fp.Size, fp.Bytes, f.MaxMemory)
+}
@@ -0,0 +1,27 @@
+package luminance_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/luminance"
+)
+
+func TestMaxMemory(t *testing.T) {
+	mi := images["base"]
+
+	filter := luminance.New(mi, 1, 0.001)
+	filter.MaxMemory = 1 << 14
+	if err := filter.Execute(); !errors.Is(err, bilateral.ErrMemoryLimit) {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", bilateral.ErrMemoryLimit, err)
+	}
+
+	filter.Coarsen = true
+	if err := filter.Execute(); err != nil {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	if fp := filter.Footprint(); fp.Bytes > filter.MaxMemory {
+		t.Errorf("%s: expected lower than %d, actual: %d", "Bytes", filter.MaxMemory, fp.Bytes)
+	}
+}
@@ -0,0 +1,45 @@
+package luminance_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mdouchement/bilateral/luminance"
+)
+
+func TestFastBilateralMask(t *testing.T) {
+	// Flat image with a darker band on its top rows, masked out
+	m := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	mask := image.NewAlpha(m.Bounds())
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if y < 8 {
+				m.Set(x, y, color.Gray{Y: 40})
+				continue
+			}
+			m.Set(x, y, color.Gray{Y: 60})
+			mask.SetAlpha(x, y, color.Alpha{A: 255})
+		}
+	}
+
+	filter := luminance.New(m, 8, 0.5)
+	filter.Execute()
+	if r := filter.At(16, 8).(color.RGBA).R; r >= 59 {
+		t.Errorf("%s: expected: %v, actual: %v", "Unmasked", "< 59", r)
+	}
+
+	filter = luminance.New(m, 8, 0.5)
+	filter.Mask = mask
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	result := filter.ResultImage()
+	if c := result.At(16, 7); c != m.At(16, 7) {
+		t.Errorf("%s: expected: %v, actual: %v", "Copied", m.At(16, 7), c)
+	}
+	if r := result.At(16, 8).(color.RGBA).R; r < 59 {
+		t.Errorf("%s: expected: %v, actual: %v", "Masked", ">= 59", r)
+	}
+}
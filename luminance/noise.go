@@ -0,0 +1,37 @@
+package luminance
+
+import (
+	"image"
+	"math"
+
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+const (
+	// Ratio between SigmaRange and the estimated noise.
+	noiseFactor = 3
+	// Lower bound of the estimated SigmaRange.
+	minSigmaRange = 0.01
+)
+
+// AutoNoise instanciates a new FastBilateral with sigma values picked from the measured luminance noise.
+// The chosen values are returned alongside the filter.
+func AutoNoise(m image.Image) (*FastBilateral, bilateral.Sigmas) {
+	s := EstimateSigmas(m)
+	return New(m, s.Space, s.Range), s
+}
+
+// EstimateSigmas measures the luminance noise level of the given image and picks the sigma values from it.
+func EstimateSigmas(m image.Image) bilateral.Sigmas {
+	var s bilateral.Sigmas
+	s.Noise = bilateral.EstimateNoise(m, func(r, g, b uint32) float64 {
+		_, Y, _ := colorful.LinearRgbToXyz(float64(r)/util.MaxRange, float64(g)/util.MaxRange, float64(b)/util.MaxRange)
+		return Y
+	})
+
+	s.Range = math.Max(noiseFactor*s.Noise, minSigmaRange)
+	s.Space = bilateral.SigmaSpaceFor(m.Bounds())
+	return s
+}
@@ -0,0 +1,24 @@
+package luminance_test
+
+import (
+	"testing"
+
+	"github.com/mdouchement/bilateral/luminance"
+)
+
+func TestAutoNoise(t *testing.T) {
+	mi := images["base"]
+
+	filter, sigmas := luminance.AutoNoise(mi)
+	if filter.SigmaSpace != sigmas.Space || filter.SigmaRange != sigmas.Range {
+		t.Errorf("%s: expected: %#v, actual: %f %f", "Sigmas", sigmas, filter.SigmaSpace, filter.SigmaRange)
+	}
+	if sigmas.Range < sigmas.Noise {
+		t.Errorf("%s: expected greater than %f, actual: %f", "SigmaRange", sigmas.Noise, sigmas.Range)
+	}
+
+	filter.Execute()
+	if !filter.Bounds().Eq(filter.ResultImage().Bounds()) {
+		t.Errorf("%s: expected: %v, actual: %v", "Bounds", filter.Bounds(), filter.ResultImage().Bounds())
+	}
+}
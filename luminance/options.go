@@ -0,0 +1,101 @@
+package luminance
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/mdouchement/bilateral"
+)
+
+// An Option configures a FastBilateral instanciated by NewWithOptions.
+type Option func(f *FastBilateral) error
+
+// NewWithOptions instanciates a new FastBilateral configured by the given options, applied in order.
+// The sigma values are automatic unless WithSigmas is given.
+// It returns an error wrapping bilateral.ErrInvalidOption if the image or an option is invalid.
+func NewWithOptions(img image.Image, options ...Option) (*FastBilateral, error) {
+	if img == nil {
+		return nil, fmt.Errorf("%w: nil image", bilateral.ErrInvalidOption)
+	}
+
+	f := Auto(img)
+	for _, option := range options {
+		if err := option(f); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// WithSigmas sets the spatial and range sigma values, which must be positive and finite.
+func WithSigmas(sigmaSpace, sigmaRange float64) Option {
+	return func(f *FastBilateral) error {
+		if !positive(sigmaSpace) {
+			return fmt.Errorf("%w: sigma space must be positive and finite, got %v", bilateral.ErrInvalidOption, sigmaSpace)
+		}
+		if !positive(sigmaRange) {
+			return fmt.Errorf("%w: sigma range must be positive and finite, got %v", bilateral.ErrInvalidOption, sigmaRange)
+		}
+
+		f.SigmaSpace = sigmaSpace
+		f.SigmaRange = sigmaRange
+		f.auto = false
+		return nil
+	}
+}
+
+// WithAuto computes the sigma range from the luminance bounds, like Auto.
+func WithAuto() Option {
+	return func(f *FastBilateral) error {
+		f.auto = true
+		return nil
+	}
+}
+
+// WithKernels sets the blur kernel of each grid axis (x, y, then the luminance).
+// Zero kernels fall back to BinomialKernel.
+func WithKernels(kernels ...bilateral.Kernel) Option {
+	return func(f *FastBilateral) error {
+		for axis, k := range kernels {
+			if err := k.Validate(); err != nil {
+				return fmt.Errorf("%w: kernel of axis %d: %v", bilateral.ErrInvalidOption, axis, err)
+			}
+		}
+
+		f.Kernels = kernels
+		return nil
+	}
+}
+
+// WithRangeKernel sets the kernel of the luminance axis, e.g. a robust TukeyKernel.
+func WithRangeKernel(k bilateral.Kernel) Option {
+	return func(f *FastBilateral) error {
+		if len(k.Weights) == 0 {
+			return fmt.Errorf("%w: range kernel has no weights", bilateral.ErrInvalidOption)
+		}
+		if err := k.Validate(); err != nil {
+			return fmt.Errorf("%w: range kernel: %v", bilateral.ErrInvalidOption, err)
+		}
+
+		f.RangeKernel = k
+		return nil
+	}
+}
+
+// WithProgress sets the func called with the progress of Execute's phases and of the slicing.
+func WithProgress(fn bilateral.ProgressFunc) Option {
+	return func(f *FastBilateral) error {
+		if fn == nil {
+			return fmt.Errorf("%w: nil progress func", bilateral.ErrInvalidOption)
+		}
+
+		f.OnProgress = fn
+		return nil
+	}
+}
+
+// positive returns true if v is positive and finite.
+func positive(v float64) bool {
+	return v > 0 && !math.IsInf(v, 1)
+}
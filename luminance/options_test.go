@@ -0,0 +1,100 @@
+package luminance_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/luminance"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	mi := images["base"]
+	mo := images["filtered"]
+
+	filter, err := luminance.NewWithOptions(mi)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewWithOptions", nil, err)
+	}
+	filter.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "Auto", mo, filter.ResultImage())
+	}
+
+	filter, err = luminance.NewWithOptions(mi, luminance.WithSigmas(4, 0.2), luminance.WithKernels(bilateral.BinomialKernel()))
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewWithOptions", nil, err)
+	}
+	filter.Execute()
+	expected := luminance.New(mi, 4, 0.2)
+	expected.Kernels = []bilateral.Kernel{bilateral.BinomialKernel()}
+	expected.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), expected.ResultImage()) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "WithSigmas", expected.ResultImage(), filter.ResultImage())
+	}
+}
+
+func TestNewWithOptionsErrors(t *testing.T) {
+	mi := images["base-gray"]
+
+	for name, options := range map[string][]luminance.Option{
+		"Zero sigma space":     {luminance.WithSigmas(0, 0.1)},
+		"Negative sigma range": {luminance.WithSigmas(16, -0.1)},
+		"NaN sigma range":      {luminance.WithSigmas(16, math.NaN())},
+		"Infinite sigma space": {luminance.WithSigmas(math.Inf(1), 0.1)},
+		"Kernels":              {luminance.WithKernels(bilateral.BinomialKernel(), bilateral.Kernel{Weights: []float64{-1}})},
+		"Range kernel":         {luminance.WithRangeKernel(bilateral.Kernel{})},
+		"Progress":             {luminance.WithProgress(nil)},
+	} {
+		if _, err := luminance.NewWithOptions(mi, options...); !errors.Is(err, bilateral.ErrInvalidOption) {
+			t.Errorf("%s: expected: %v, actual: %v", name, bilateral.ErrInvalidOption, err)
+		}
+	}
+
+	if _, err := luminance.NewWithOptions(nil); !errors.Is(err, bilateral.ErrInvalidOption) {
+		t.Errorf("%s: expected: %v, actual: %v", "Nil image", bilateral.ErrInvalidOption, err)
+	}
+}
+
+func TestExecuteInvalid(t *testing.T) {
+	mi := images["base-gray"]
+
+	for name, filter := range map[string]*luminance.FastBilateral{
+		"Zero sigma space":     luminance.New(mi, 0, 0.1),
+		"Negative sigma space": luminance.New(mi, -4, 0.1),
+		"Zero sigma range":     luminance.New(mi, 16, 0),
+		"NaN sigma range":      luminance.New(mi, 16, math.NaN()),
+		"Kernels": func() *luminance.FastBilateral {
+			f := luminance.New(mi, 16, 0.1)
+			f.Kernels = []bilateral.Kernel{{Weights: []float64{-1}}}
+			return f
+		}(),
+	} {
+		if err := filter.Execute(); !errors.Is(err, bilateral.ErrInvalidOption) {
+			t.Errorf("%s: expected: %v, actual: %v", name, bilateral.ErrInvalidOption, err)
+		}
+	}
+}
+
+func TestAutoFlat(t *testing.T) {
+	mi := image.NewGray(image.Rect(0, 0, 16, 16))
+	draw.Draw(mi, mi.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+
+	filter := luminance.Auto(mi)
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Auto", nil, err)
+	}
+	if filter.SigmaRange <= 0 {
+		t.Errorf("%s: expected: %v, actual: %v", "SigmaRange", "> 0", filter.SigmaRange)
+	}
+	if actual := color.GrayModel.Convert(filter.At(8, 8)).(color.Gray); actual.Y < 127 || actual.Y > 129 {
+		t.Errorf("%s: expected: %v, actual: %v", "At", color.Gray{Y: 128}, actual)
+	}
+}
@@ -0,0 +1,100 @@
+package luminance
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+type (
+	// A Signal filter is a bilateral filter for 1-D signals, like sensor time series.
+	// It smooths the samples while keeping the step changes sharp.
+	// The samples are filtered in the luminance grid as a single row image, whose vertical axis is not blurred.
+	Signal struct {
+		Samples []float64
+		// Spacing is the distance between two samples (e.g. the sampling period), 1 when not positive.
+		Spacing    float64
+		SigmaRange float64
+		// SigmaSpace is expressed in the Spacing's unit.
+		SigmaSpace float64
+		// Kernels holds the blur kernel of the time axis, then of the values.
+		// Missing or zero kernels fall back to bilateral.BinomialKernel.
+		Kernels []bilateral.Kernel
+		// Splatting defines how the samples are accumulated into the grid, bilateral.Nearest by default.
+		Splatting bilateral.Splatting
+		// Interpolation defines how the grid is sliced, bilateral.Linear by default.
+		Interpolation bilateral.Interpolation
+		filter        *FastBilateral
+	}
+
+	// samples adapts a signal to the image.Image interface, as a single row gray image.
+	samples struct {
+		values []float64
+	}
+)
+
+// NewSignal instanciates a new Signal filter.
+func NewSignal(values []float64, spacing, sigmaSpace, sigmaRange float64) *Signal {
+	return &Signal{
+		Samples:    values,
+		Spacing:    spacing,
+		SigmaRange: sigmaRange,
+		SigmaSpace: sigmaSpace,
+	}
+}
+
+// Execute runs the bilateral filter.
+func (s *Signal) Execute() error {
+	spacing := s.Spacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+
+	s.filter = New(&samples{values: s.Samples}, s.SigmaSpace/spacing, s.SigmaRange)
+	s.filter.Kernels = []bilateral.Kernel{
+		s.kernel(0),
+		{Weights: []float64{1}}, // Single row, nothing to blur
+		s.kernel(1),
+	}
+	s.filter.Splatting = s.Splatting
+	s.filter.Interpolation = s.Interpolation
+	return s.filter.Execute()
+}
+
+// Result computes the interpolation and returns the filtered samples.
+func (s *Signal) Result() []float64 {
+	filtered := make([]float64, len(s.Samples))
+	for x, v := range s.Samples {
+		filtered[x] = s.filter.filtered(x, 0, v)
+	}
+	return filtered
+}
+
+// kernel returns the kernel of the given signal axis, bilateral.BinomialKernel by default.
+func (s *Signal) kernel(axis int) bilateral.Kernel {
+	if axis < len(s.Kernels) && len(s.Kernels[axis].Weights) > 0 {
+		return s.Kernels[axis]
+	}
+	return bilateral.BinomialKernel()
+}
+
+// ColorModel implements image.Image interface.
+func (s *samples) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+// Bounds implements image.Image interface.
+func (s *samples) Bounds() image.Rectangle {
+	return image.Rect(0, 0, len(s.values), 1)
+}
+
+// At implements image.Image interface.
+func (s *samples) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(s.Bounds())) {
+		return color.Gray16{}
+	}
+	return color.Gray16{Y: uint16(math.Min(math.Max(s.values[x], 0), 1)*util.MaxRange + 0.5)}
+}
@@ -0,0 +1,58 @@
+package luminance_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/luminance"
+)
+
+// steps returns a noisy signal stepping from 10 to 20 at the sample 50.
+func steps() []float64 {
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = 10
+		if i >= 50 {
+			values[i] = 20
+		}
+		values[i] += 0.5 * float64(i*7%5-2)
+	}
+	return values
+}
+
+func TestSignal(t *testing.T) {
+	for _, interpolation := range []bilateral.Interpolation{bilateral.Linear, bilateral.Cubic} {
+		signal := luminance.NewSignal(steps(), 0.5, 4, 2)
+		signal.Interpolation = interpolation
+		if err := signal.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", interpolation, nil, err)
+		}
+
+		filtered := signal.Result()
+		for i := 5; i < 95; i++ {
+			expected := 10.0
+			if i >= 50 {
+				expected = 20
+			}
+			if math.Abs(filtered[i]-expected) > 0.3 {
+				t.Fatalf("%s(%d): expected: %v, actual: %v", interpolation, i, expected, filtered[i])
+			}
+		}
+	}
+}
+
+func TestSignalSpacing(t *testing.T) {
+	// The same sigma in samples gives the same result
+	signal := luminance.NewSignal(steps(), 0.5, 4, 2)
+	signal.Execute()
+	expected := signal.Result()
+
+	signal = luminance.NewSignal(steps(), 1, 8, 2)
+	signal.Execute()
+	for i, v := range signal.Result() {
+		if v != expected[i] {
+			t.Fatalf("%s(%d): expected: %v, actual: %v", "Result", i, expected[i], v)
+		}
+	}
+}
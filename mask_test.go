@@ -0,0 +1,50 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+// letterbox returns a flat image with a darker band on its top rows and the mask of the other rows.
+func letterbox() (image.Image, image.Image) {
+	m := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	mask := image.NewAlpha(m.Bounds())
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if y < 8 {
+				m.Set(x, y, color.RGBA{R: 40, G: 40, B: 40, A: 255})
+				continue
+			}
+			m.Set(x, y, color.RGBA{R: 90, G: 60, B: 30, A: 255})
+			mask.SetAlpha(x, y, color.Alpha{A: 255})
+		}
+	}
+	return m, mask
+}
+
+func TestFastBilateralMask(t *testing.T) {
+	m, mask := letterbox()
+
+	filter := bilateral.New(m, 8, 0.5)
+	filter.Execute()
+	if g := filter.At(16, 8).(color.RGBA).G; g >= 59 {
+		t.Errorf("%s: expected: %v, actual: %v", "Unmasked", "< 59", g)
+	}
+
+	filter = bilateral.New(m, 8, 0.5)
+	filter.Mask = mask
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	result := filter.ResultImage()
+	if c := result.At(16, 7); c != m.At(16, 7) {
+		t.Errorf("%s: expected: %v, actual: %v", "Copied", m.At(16, 7), c)
+	}
+	if g := result.At(16, 8).(color.RGBA).G; g < 59 {
+		t.Errorf("%s: expected: %v, actual: %v", "Masked", ">= 59", g)
+	}
+}
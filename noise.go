@@ -0,0 +1,119 @@
+package bilateral
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+const (
+	// Upper bound of residuals sampled by EstimateNoise.
+	noiseSamples = 1 << 20
+	// Ratio between the standard deviation and the median absolute deviation of a normal distribution.
+	madScale = 0.6745
+	// Ratio between SigmaRange and the estimated noise.
+	noiseFactor = 3
+	// Lower bound of the estimated SigmaRange, the colour grid grows with the cube of 1/SigmaRange.
+	minSigmaRange = 0.05
+	// Ratio between SigmaSpace and the image diagonal.
+	spaceFactor = 0.02
+	// Lower bound of the estimated SigmaSpace.
+	minSigmaSpace = 2
+)
+
+// Sigmas holds the parameters picked by an automatic estimation.
+type Sigmas struct {
+	Space float64 // SigmaSpace
+	Range float64 // SigmaRange
+	Noise float64 // Estimated noise standard deviation
+}
+
+// AutoNoise instanciates a new FastBilateral with sigma values picked from the measured image noise.
+// The chosen values are returned alongside the filter.
+func AutoNoise(m image.Image) (*FastBilateral, Sigmas) {
+	s := EstimateSigmas(m)
+	return New(m, s.Space, s.Range), s
+}
+
+// EstimateSigmas measures the noise level of the given image and picks the sigma values from it.
+// SigmaRange is a multiple of the noisiest channel's standard deviation and
+// SigmaSpace is proportional to the image diagonal.
+func EstimateSigmas(m image.Image) Sigmas {
+	var s Sigmas
+	channels := []func(r, g, b uint32) float64{
+		func(r, _, _ uint32) float64 { return util.Color(r) },
+		func(_, g, _ uint32) float64 { return util.Color(g) },
+		func(_, _, b uint32) float64 { return util.Color(b) },
+	}
+	for _, channel := range channels {
+		s.Noise = math.Max(s.Noise, EstimateNoise(m, channel))
+	}
+
+	s.Range = math.Max(noiseFactor*s.Noise, minSigmaRange)
+	s.Space = SigmaSpaceFor(m.Bounds())
+	return s
+}
+
+// SigmaSpaceFor returns a SigmaSpace proportional to the diagonal of the given bounds.
+func SigmaSpaceFor(d image.Rectangle) float64 {
+	diagonal := math.Hypot(float64(d.Dx()), float64(d.Dy()))
+	return math.Max(spaceFactor*diagonal, minSigmaSpace)
+}
+
+// EstimateNoise returns the standard deviation of the noise of the given channel.
+// It computes the median absolute deviation of the image's high-pass residual (Immerkær's Laplacian mask),
+// which is robust to edges and outlier pixels. Large images are subsampled.
+func EstimateNoise(m image.Image, channel func(r, g, b uint32) float64) float64 {
+	d := m.Bounds()
+	if d.Dx() < 3 || d.Dy() < 3 {
+		return 0
+	}
+
+	step := 1
+	for (d.Dx()-2)/step*((d.Dy()-2)/step) > noiseSamples {
+		step++
+	}
+
+	// 3x3 mask:
+	//  1 -2  1
+	// -2  4 -2
+	//  1 -2  1
+	mask := [3][3]float64{{1, -2, 1}, {-2, 4, -2}, {1, -2, 1}}
+	var residuals []float64
+	for y := d.Min.Y + 1; y < d.Max.Y-1; y += step {
+		for x := d.Min.X + 1; x < d.Max.X-1; x += step {
+			var v float64
+			for j := -1; j <= 1; j++ {
+				for i := -1; i <= 1; i++ {
+					r, g, b, _ := m.At(x+i, y+j).RGBA()
+					v += mask[j+1][i+1] * channel(r, g, b)
+				}
+			}
+			residuals = append(residuals, v)
+		}
+	}
+
+	med := median(residuals)
+	for i, v := range residuals {
+		residuals[i] = math.Abs(v - med)
+	}
+
+	// The mask's squared weights sum to 36.
+	return median(residuals) / madScale / 6
+}
+
+// median sorts the given values and returns their median.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,45 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestEstimateNoise(t *testing.T) {
+	sigma := 0.02
+	rnd := rand.New(rand.NewSource(42))
+	m := image.NewGray16(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			v := 0.25 + 0.5*float64(x)/128 + rnd.NormFloat64()*sigma // gradient + noise
+			m.SetGray16(x, y, color.Gray16{Y: uint16(v * 65535)})
+		}
+	}
+
+	noise := bilateral.EstimateNoise(m, func(r, _, _ uint32) float64 { return float64(r) / 65535 })
+	if math.Abs(noise-sigma) > 0.1*sigma {
+		t.Errorf("%s: expected: %f, actual: %f", "EstimateNoise", sigma, noise)
+	}
+}
+
+func TestAutoNoise(t *testing.T) {
+	mi := images["base-gray"]
+
+	filter, sigmas := bilateral.AutoNoise(mi)
+	if filter.SigmaSpace != sigmas.Space || filter.SigmaRange != sigmas.Range {
+		t.Errorf("%s: expected: %#v, actual: %f %f", "Sigmas", sigmas, filter.SigmaSpace, filter.SigmaRange)
+	}
+	if sigmas.Range < sigmas.Noise {
+		t.Errorf("%s: expected greater than %f, actual: %f", "SigmaRange", sigmas.Noise, sigmas.Range)
+	}
+
+	filter.Execute()
+	if !filter.Bounds().Eq(filter.ResultImage().Bounds()) {
+		t.Errorf("%s: expected: %v, actual: %v", "Bounds", filter.Bounds(), filter.ResultImage().Bounds())
+	}
+}
@@ -0,0 +1,139 @@
+package bilateral
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// ErrInvalidOption is returned when NewWithOptions is given an invalid option.
+var ErrInvalidOption = errors.New("bilateral: invalid option")
+
+// An Option configures a FastBilateral instanciated by NewWithOptions.
+type Option func(f *FastBilateral) error
+
+// NewWithOptions instanciates a new FastBilateral configured by the given options, applied in order.
+// The sigma values are automatic unless WithSigmas is given.
+// It returns an error wrapping ErrInvalidOption if the image or an option is invalid.
+func NewWithOptions(img image.Image, options ...Option) (*FastBilateral, error) {
+	if img == nil {
+		return nil, fmt.Errorf("%w: nil image", ErrInvalidOption)
+	}
+
+	f := Auto(img)
+	for _, option := range options {
+		if err := option(f); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// WithSigmas sets the spatial and range sigma values, which must be positive and finite.
+func WithSigmas(sigmaSpace, sigmaRange float64) Option {
+	return func(f *FastBilateral) error {
+		if !positive(sigmaSpace) {
+			return fmt.Errorf("%w: sigma space must be positive and finite, got %v", ErrInvalidOption, sigmaSpace)
+		}
+		if !positive(sigmaRange) {
+			return fmt.Errorf("%w: sigma range must be positive and finite, got %v", ErrInvalidOption, sigmaRange)
+		}
+
+		f.SigmaSpace = sigmaSpace
+		f.SigmaRange = sigmaRange
+		f.auto = false
+		return nil
+	}
+}
+
+// WithAuto computes the sigma values from the image's range bounds, like Auto.
+func WithAuto() Option {
+	return func(f *FastBilateral) error {
+		f.auto = true
+		return nil
+	}
+}
+
+// WithColorSpace sets the colour space of the range axes.
+func WithColorSpace(cs ColorSpace) Option {
+	return func(f *FastBilateral) error {
+		if cs < RGB || cs > Lab {
+			return fmt.Errorf("%w: unknown color space %d", ErrInvalidOption, cs)
+		}
+
+		f.ColorSpace = cs
+		return nil
+	}
+}
+
+// WithWorkers sets the number of goroutines slicing the grid, which must be positive.
+func WithWorkers(n int) Option {
+	return func(f *FastBilateral) error {
+		if n < 1 {
+			return fmt.Errorf("%w: workers must be positive, got %d", ErrInvalidOption, n)
+		}
+
+		f.Workers = n
+		return nil
+	}
+}
+
+// WithKernels sets the blur kernel of each grid axis (x, y, then the colour depths).
+// Zero kernels fall back to BinomialKernel.
+func WithKernels(kernels ...Kernel) Option {
+	return func(f *FastBilateral) error {
+		for axis, k := range kernels {
+			if err := k.Validate(); err != nil {
+				return fmt.Errorf("%w: kernel of axis %d: %v", ErrInvalidOption, axis, err)
+			}
+		}
+
+		f.Kernels = kernels
+		return nil
+	}
+}
+
+// WithRangeKernel sets the kernel of the colour depths, e.g. a robust TukeyKernel.
+func WithRangeKernel(k Kernel) Option {
+	return func(f *FastBilateral) error {
+		if len(k.Weights) == 0 {
+			return fmt.Errorf("%w: range kernel has no weights", ErrInvalidOption)
+		}
+		if err := k.Validate(); err != nil {
+			return fmt.Errorf("%w: range kernel: %v", ErrInvalidOption, err)
+		}
+
+		f.RangeKernel = k
+		return nil
+	}
+}
+
+// WithOutput sets the image type returned by ResultImage.
+func WithOutput(o Output) Option {
+	return func(f *FastBilateral) error {
+		if o < OutputRGBA || o > OutputFloat {
+			return fmt.Errorf("%w: unknown output %d", ErrInvalidOption, o)
+		}
+
+		f.Output = o
+		return nil
+	}
+}
+
+// WithProgress sets the func called with the progress of Execute's phases and of the slicing.
+func WithProgress(fn ProgressFunc) Option {
+	return func(f *FastBilateral) error {
+		if fn == nil {
+			return fmt.Errorf("%w: nil progress func", ErrInvalidOption)
+		}
+
+		f.OnProgress = fn
+		return nil
+	}
+}
+
+// positive returns true if v is positive and finite.
+func positive(v float64) bool {
+	return v > 0 && !math.IsInf(v, 1)
+}
@@ -0,0 +1,171 @@
+package bilateral_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	mi := images["base"]
+	mo := images["filtered"]
+
+	filter, err := bilateral.NewWithOptions(mi)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewWithOptions", nil, err)
+	}
+	filter.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), mo) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "Auto", mo, filter.ResultImage())
+	}
+
+	filter, err = bilateral.NewWithOptions(mi, bilateral.WithSigmas(4, 0.2), bilateral.WithWorkers(4))
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewWithOptions", nil, err)
+	}
+	filter.Execute()
+	sequential := bilateral.New(mi, 4, 0.2)
+	sequential.Execute()
+
+	if !reflect.DeepEqual(filter.ResultImage(), sequential.ResultImage()) {
+		t.Errorf("%s: expected: %#v, actual: %#v", "Workers", sequential.ResultImage(), filter.ResultImage())
+	}
+}
+
+func TestNewWithOptionsErrors(t *testing.T) {
+	mi := images["base-gray"]
+
+	for name, options := range map[string][]bilateral.Option{
+		"Zero sigma space":     {bilateral.WithSigmas(0, 0.1)},
+		"Negative sigma range": {bilateral.WithSigmas(16, -0.1)},
+		"NaN sigma range":      {bilateral.WithSigmas(16, math.NaN())},
+		"Infinite sigma space": {bilateral.WithSigmas(math.Inf(1), 0.1)},
+		"Color space":          {bilateral.WithColorSpace(bilateral.ColorSpace(42))},
+		"Workers":              {bilateral.WithWorkers(0)},
+		"Kernels":              {bilateral.WithKernels(bilateral.BinomialKernel(), bilateral.Kernel{Weights: []float64{-1}})},
+		"Range kernel":         {bilateral.WithRangeKernel(bilateral.Kernel{})},
+		"Output":               {bilateral.WithOutput(bilateral.Output(-1))},
+	} {
+		if _, err := bilateral.NewWithOptions(mi, options...); !errors.Is(err, bilateral.ErrInvalidOption) {
+			t.Errorf("%s: expected: %v, actual: %v", name, bilateral.ErrInvalidOption, err)
+		}
+	}
+
+	if _, err := bilateral.NewWithOptions(nil); !errors.Is(err, bilateral.ErrInvalidOption) {
+		t.Errorf("%s: expected: %v, actual: %v", "Nil image", bilateral.ErrInvalidOption, err)
+	}
+}
+
+func TestExecuteInvalidSigmas(t *testing.T) {
+	mi := images["base-gray"]
+
+	for name, filter := range map[string]interface{ Execute() error }{
+		"Zero sigma space":     bilateral.New(mi, 0, 0.1),
+		"NaN sigma space":      bilateral.New(mi, math.NaN(), 0.1),
+		"Zero sigma range":     bilateral.New(mi, 4, 0),
+		"Infinite sigma range": bilateral.New(mi, 4, math.Inf(1)),
+		"Infinite sigma space y": func() *bilateral.FastBilateral {
+			f := bilateral.New(mi, 4, 0.1)
+			f.SigmaSpaceY = math.Inf(1)
+			return f
+		}(),
+		"Infinite sigma ranges": func() *bilateral.FastBilateral {
+			f := bilateral.New(mi, 4, 0.1)
+			f.SigmaRanges = []float64{0.1, math.Inf(1), 0.1}
+			return f
+		}(),
+		"Varying":     bilateral.NewVarying(mi, 0, mi),
+		"Rolling":     bilateral.NewRollingGuidance(mi, 4, 0, 2),
+		"Abstraction": bilateral.NewAbstraction(mi, 4, -0.1),
+	} {
+		if err := filter.Execute(); !errors.Is(err, bilateral.ErrInvalidOption) {
+			t.Errorf("%s: expected: %v, actual: %v", name, bilateral.ErrInvalidOption, err)
+		}
+	}
+
+	tiled := bilateral.NewTiled(bilateral.ImageTileReader{Image: mi}, 0, 0.1)
+	if err := tiled.Execute(bilateral.ImageTileWriter{Image: image.NewRGBA(mi.Bounds())}); !errors.Is(err, bilateral.ErrInvalidOption) {
+		t.Errorf("%s: expected: %v, actual: %v", "Tiled", bilateral.ErrInvalidOption, err)
+	}
+}
+
+func TestAutoFlat(t *testing.T) {
+	mi := image.NewGray(image.Rect(0, 0, 16, 16))
+	draw.Draw(mi, mi.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+
+	filter := bilateral.Auto(mi)
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Auto", nil, err)
+	}
+	if filter.SigmaRange <= 0 {
+		t.Errorf("%s: expected: %v, actual: %v", "SigmaRange", "> 0", filter.SigmaRange)
+	}
+	if actual := color.GrayModel.Convert(filter.At(8, 8)).(color.Gray); actual.Y < 127 || actual.Y > 129 {
+		t.Errorf("%s: expected: %v, actual: %v", "At", color.Gray{Y: 128}, actual)
+	}
+
+	options, err := bilateral.NewWithOptions(mi)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewWithOptions", nil, err)
+	}
+	if err = options.Execute(); err != nil {
+		t.Errorf("%s: expected: %v, actual: %v", "NewWithOptions", nil, err)
+	}
+}
+
+func TestFastBilateralOutput(t *testing.T) {
+	mi := images["base-gray"]
+
+	for output, expected := range map[bilateral.Output]image.Image{
+		bilateral.OutputRGBA:   &image.RGBA{},
+		bilateral.OutputRGBA64: &image.RGBA64{},
+		bilateral.OutputFloat:  &bilateral.FloatImage{},
+	} {
+		filter, err := bilateral.NewWithOptions(mi, bilateral.WithSigmas(16, 0.1), bilateral.WithOutput(output))
+		if err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", output, nil, err)
+		}
+		filter.Execute()
+
+		m := filter.ResultImage()
+		if reflect.TypeOf(m) != reflect.TypeOf(expected) {
+			t.Errorf("%s: expected: %T, actual: %T", output, expected, m)
+		}
+		if !m.Bounds().Eq(mi.Bounds()) {
+			t.Errorf("%s: expected: %v, actual: %v", output, mi.Bounds(), m.Bounds())
+		}
+	}
+}
+
+func TestFastBilateralColorSpace(t *testing.T) {
+	teal := color.RGBA{R: 30, G: 140, B: 120, A: 255}
+	m := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			m.Set(x, y, teal)
+		}
+	}
+
+	for _, cs := range []bilateral.ColorSpace{bilateral.RGB, bilateral.YCbCr, bilateral.Lab} {
+		filter, err := bilateral.NewWithOptions(m, bilateral.WithSigmas(4, 0.1), bilateral.WithColorSpace(cs))
+		if err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", cs, nil, err)
+		}
+		filter.Execute()
+
+		r, g, b, _ := filter.ResultFloat().FloatAt(8, 8)
+		for i, v := range []float64{r, g, b} {
+			expected := float64([]uint8{teal.R, teal.G, teal.B}[i]) / 255
+			if math.Abs(v-expected) > 1e-6 {
+				t.Errorf("%s[%d]: expected: %f, actual: %f", cs, i, expected, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package bilateral
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// Output defines the image type returned by ResultImage.
+type Output int
+
+const (
+	// OutputRGBA returns an 8-bit *image.RGBA (default).
+	OutputRGBA Output = iota
+	// OutputRGBA64 returns a 16-bit *image.RGBA64.
+	OutputRGBA64
+	// OutputFloat returns a *FloatImage, without quantization.
+	OutputFloat
+)
+
+// String implements fmt.Stringer interface.
+func (o Output) String() string {
+	switch o {
+	case OutputRGBA:
+		return "rgba"
+	case OutputRGBA64:
+		return "rgba64"
+	case OutputFloat:
+		return "float"
+	default:
+		return "unknown"
+	}
+}
+
+// result64 computes the interpolation and returns the filtered pixels within r with a 16-bit precision.
+func (f *FastBilateral) result64(r image.Rectangle) *image.RGBA64 {
+	channel := func(v float64) uint16 {
+		return uint16(util.Clampf(0, 1, v)*util.MaxRange + 0.5)
+	}
+
+	dst := image.NewRGBA64(r)
+	f.rows(r, func(y int) {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			rgb, a := f.filtered(x, y)
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: channel(rgb[c1]),
+				G: channel(rgb[c2]),
+				B: channel(rgb[c3]),
+				A: channel(a),
+			})
+		}
+	})
+	return dst
+}
+
+// rows calls fn for each row of r, spread over Workers goroutines, and reports the slicing progress.
+func (f *FastBilateral) rows(r image.Rectangle, fn func(y int)) {
+	step := f.OnProgress.Counter(PhaseSlicing, r.Dy())
+	if f.Workers <= 1 {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			fn(y)
+			step()
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for i := 0; i < f.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range next {
+				fn(y)
+				step()
+			}
+		}()
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		next <- y
+	}
+	close(next)
+	wg.Wait()
+}
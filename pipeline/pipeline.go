@@ -0,0 +1,60 @@
+// Package pipeline chains image processing stages through bilateral.FloatImage buffers,
+// so multi-step filters do not quantize their intermediate results to 8 bits.
+package pipeline
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/mdouchement/bilateral"
+)
+
+type (
+	// A Stage processes a float image and returns the processed image.
+	// It must not modify the given image, which may be the pipeline's input.
+	Stage interface {
+		Apply(m *bilateral.FloatImage) (*bilateral.FloatImage, error)
+	}
+
+	// StageFunc adapts a function to the Stage interface.
+	StageFunc func(m *bilateral.FloatImage) (*bilateral.FloatImage, error)
+
+	// A Pipeline runs its stages in order, each stage processing the previous stage's result.
+	Pipeline struct {
+		Stages []Stage
+	}
+)
+
+// New instanciates a new Pipeline of the given stages.
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{Stages: stages}
+}
+
+// Then appends the given stages to the pipeline and returns the pipeline.
+func (p *Pipeline) Then(stages ...Stage) *Pipeline {
+	p.Stages = append(p.Stages, stages...)
+	return p
+}
+
+// Run converts the given image to a float image once and runs the stages on it.
+// It returns the first stage error, prefixed by the stage's index.
+func (p *Pipeline) Run(m image.Image) (*bilateral.FloatImage, error) {
+	fm := bilateral.ToFloatImage(m)
+	for i, stage := range p.Stages {
+		var err error
+		if fm, err = stage.Apply(fm); err != nil {
+			return nil, fmt.Errorf("pipeline: stage %d: %w", i, err)
+		}
+	}
+	return fm, nil
+}
+
+// Apply implements Stage interface, so a pipeline can be nested in another one.
+func (p *Pipeline) Apply(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+	return p.Run(m)
+}
+
+// Apply implements Stage interface.
+func (fn StageFunc) Apply(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+	return fn(m)
+}
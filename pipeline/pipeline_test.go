@@ -0,0 +1,112 @@
+package pipeline_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/pipeline"
+)
+
+// checkerboard returns an image whose left half is a dark and bright checkerboard and right half is bright.
+func checkerboard() image.Image {
+	m := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := uint8(200)
+			if x < 16 && (x+y)%2 == 0 {
+				v = 40
+			}
+			m.Set(x, y, color.RGBA{R: v, G: v / 2, B: v / 4, A: 255})
+		}
+	}
+	return m
+}
+
+func TestPipelineBilateral(t *testing.T) {
+	m := checkerboard()
+
+	result, err := pipeline.New(pipeline.Bilateral(4, 0.1)).Run(m)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Run", nil, err)
+	}
+
+	filter := bilateral.New(bilateral.ToFloatImage(m), 4, 0.1)
+	filter.Execute()
+	if !reflect.DeepEqual(result, filter.ResultFloat()) {
+		t.Errorf("%s: expected: %v, actual: %v", "Bilateral", "same result as FastBilateral", "different result")
+	}
+}
+
+func TestPipelinePrecision(t *testing.T) {
+	m := bilateral.ToFloatImage(checkerboard())
+	gamma := func(g float64) pipeline.Stage {
+		return pipeline.ToneCurve(func(v float64) float64 { return math.Pow(v, g) })
+	}
+
+	result, err := pipeline.New(gamma(1 / 2.2)).Then(gamma(2.2)).Run(m)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Run", nil, err)
+	}
+	for i := range m.Pix {
+		if math.Abs(result.Pix[i]-m.Pix[i]) > 1e-12 {
+			t.Fatalf("%s[%d]: expected: %v, actual: %v", "Pix", i, m.Pix[i], result.Pix[i])
+		}
+	}
+}
+
+func TestPipelineStages(t *testing.T) {
+	p := pipeline.New(
+		pipeline.Luminance(4, 0.1),
+		pipeline.Filter("unknown", 4, 0.1),
+	)
+	if _, err := p.Run(checkerboard()); !errors.Is(err, bilateral.ErrUnknownFilter) {
+		t.Errorf("%s: expected: %v, actual: %v", "Filter", bilateral.ErrUnknownFilter, err)
+	}
+
+	p = pipeline.New(
+		pipeline.Luminance(4, 0.1),
+		pipeline.Filter("rolling", 4, 0.1),
+		pipeline.Sharpen(2, 0.05, 0.5),
+		pipeline.Resize(16, 8),
+	)
+	result, err := p.Run(checkerboard())
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Run", nil, err)
+	}
+	if expected := image.Rect(0, 0, 16, 8); !result.Bounds().Eq(expected) {
+		t.Errorf("%s: expected: %v, actual: %v", "Bounds", expected, result.Bounds())
+	}
+}
+
+func TestResize(t *testing.T) {
+	m := bilateral.ToFloatImage(checkerboard())
+
+	half, err := pipeline.Resize(16, 16).Apply(m)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Resize", nil, err)
+	}
+	// The checkerboard is averaged and the flat half kept
+	if r, _, _, _ := half.FloatAt(4, 8); math.Abs(r-120.0/255) > 0.02 {
+		t.Errorf("%s: expected: %f, actual: %f", "Checkerboard", 120.0/255, r)
+	}
+	if r, _, _, _ := half.FloatAt(12, 8); math.Abs(r-200.0/255) > 1e-12 {
+		t.Errorf("%s: expected: %f, actual: %f", "Flat", 200.0/255, r)
+	}
+
+	double, err := pipeline.Resize(64, 64).Apply(m)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Resize", nil, err)
+	}
+	if _, _, _, a := double.FloatAt(63, 63); math.Abs(a-1) > 1e-12 {
+		t.Errorf("%s: expected: %f, actual: %f", "Alpha", 1.0, a)
+	}
+
+	if _, err := pipeline.Resize(0, 16).Apply(m); err != pipeline.ErrSize {
+		t.Errorf("%s: expected: %v, actual: %v", "Size", pipeline.ErrSize, err)
+	}
+}
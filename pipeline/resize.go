@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// ErrSize is returned when a stage is given an invalid size.
+var ErrSize = errors.New("pipeline: invalid size")
+
+// A tap is the weight of a source pixel in a resampled pixel.
+type tap struct {
+	index  int
+	weight float64
+}
+
+// Resize returns a stage resampling the image to the given size with a triangle (bilinear) filter,
+// widened when downscaling so all the source pixels contribute.
+// The resized image's bounds start at (0, 0).
+func Resize(width, height int) Stage {
+	return StageFunc(func(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+		d := m.Bounds()
+		if width <= 0 || height <= 0 || d.Empty() {
+			return nil, ErrSize
+		}
+
+		// Horizontal pass
+		columns := taps(d.Dx(), width)
+		tmp := bilateral.NewFloatImage(image.Rect(0, 0, width, d.Dy()))
+		for y := 0; y < d.Dy(); y++ {
+			for x, row := range columns {
+				dst := tmp.Pix[tmp.PixOffset(x, y) : tmp.PixOffset(x, y)+4]
+				for _, t := range row {
+					src := m.Pix[m.PixOffset(d.Min.X+t.index, d.Min.Y+y):]
+					for c := range dst {
+						dst[c] += t.weight * src[c]
+					}
+				}
+			}
+		}
+
+		// Vertical pass
+		rows := taps(d.Dy(), height)
+		resized := bilateral.NewFloatImage(image.Rect(0, 0, width, height))
+		for y, column := range rows {
+			for x := 0; x < width; x++ {
+				dst := resized.Pix[resized.PixOffset(x, y) : resized.PixOffset(x, y)+4]
+				for _, t := range column {
+					src := tmp.Pix[tmp.PixOffset(x, t.index):]
+					for c := range dst {
+						dst[c] += t.weight * src[c]
+					}
+				}
+			}
+		}
+		return resized, nil
+	})
+}
+
+// taps returns the normalized source taps of each destination pixel, resampling n pixels to size.
+func taps(n, size int) [][]tap {
+	scale := float64(n) / float64(size)
+	support := math.Max(scale, 1)
+
+	all := make([][]tap, size)
+	for i := range all {
+		center := (float64(i) + 0.5) * scale
+		sum := 0.0
+		for j := int(math.Floor(center - support)); j <= int(math.Ceil(center+support)); j++ {
+			w := 1 - math.Abs(float64(j)+0.5-center)/support
+			if w <= 0 {
+				continue
+			}
+
+			all[i] = append(all[i], tap{index: util.Clamp(0, n-1, j), weight: w})
+			sum += w
+		}
+
+		for t := range all[i] {
+			all[i][t].weight /= sum
+		}
+	}
+	return all
+}
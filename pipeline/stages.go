@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"math"
+
+	"github.com/mdouchement/bilateral"
+	"github.com/mdouchement/bilateral/luminance"
+)
+
+// A floatResult is a filter whose result can be read without quantization.
+type floatResult interface {
+	ResultFloat() *bilateral.FloatImage
+}
+
+// Bilateral returns a stage running a bilateral.FastBilateral filter.
+// The sigma values are picked automatically when any of them is not positive.
+func Bilateral(sigmaSpace, sigmaRange float64) Stage {
+	return StageFunc(func(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+		f := bilateral.New(m, sigmaSpace, sigmaRange)
+		if sigmaSpace <= 0 || sigmaRange <= 0 {
+			f = bilateral.Auto(m)
+		}
+		if err := f.Execute(); err != nil {
+			return nil, err
+		}
+		return f.ResultFloat(), nil
+	})
+}
+
+// Luminance returns a stage running a luminance.FastBilateral filter, which only smooths the luminance.
+// The sigma values are picked automatically when any of them is not positive.
+func Luminance(sigmaSpace, sigmaRange float64) Stage {
+	return StageFunc(func(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+		f := luminance.New(m, sigmaSpace, sigmaRange)
+		if sigmaSpace <= 0 || sigmaRange <= 0 {
+			f = luminance.Auto(m)
+		}
+		if err := f.Execute(); err != nil {
+			return nil, err
+		}
+		return f.ResultFloat(), nil
+	})
+}
+
+// Filter returns a stage running the filter registered under the given name (see bilateral.NewFilter).
+// Filters without a ResultFloat method are read through their 8-bit ResultImage.
+func Filter(name string, sigmaSpace, sigmaRange float64) Stage {
+	return StageFunc(func(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+		f, err := bilateral.NewFilter(name, m, sigmaSpace, sigmaRange)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Execute(); err != nil {
+			return nil, err
+		}
+
+		if fr, ok := f.(floatResult); ok {
+			return fr.ResultFloat(), nil
+		}
+		return bilateral.ToFloatImage(f.ResultImage()), nil
+	})
+}
+
+// ToneCurve returns a stage mapping the red, green and blue channels through the given curve,
+// e.g. func(v float64) float64 { return math.Pow(v, 1/2.2) }.
+// The curve is applied to the non-premultiplied channels.
+func ToneCurve(curve func(v float64) float64) Stage {
+	return StageFunc(func(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+		dst := bilateral.NewFloatImage(m.Bounds())
+		for i := 0; i < len(m.Pix); i += 4 {
+			s := m.Pix[i : i+4 : i+4]
+			d := dst.Pix[i : i+4 : i+4]
+			d[3] = s[3]
+			if s[3] == 0 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				d[c] = curve(s[c]/s[3]) * s[3]
+			}
+		}
+		return dst, nil
+	})
+}
+
+// Sharpen returns a stage enhancing the details removed by a bilateral filter of the given sigmas,
+// amount times. Unlike an unsharp mask, the strong edges are not surrounded by halos.
+// The sharpened channels are clamped to [0, alpha].
+func Sharpen(sigmaSpace, sigmaRange, amount float64) Stage {
+	base := Bilateral(sigmaSpace, sigmaRange)
+	return StageFunc(func(m *bilateral.FloatImage) (*bilateral.FloatImage, error) {
+		smooth, err := base.Apply(m)
+		if err != nil {
+			return nil, err
+		}
+
+		d := m.Bounds()
+		dst := bilateral.NewFloatImage(d)
+		for y := d.Min.Y; y < d.Max.Y; y++ {
+			for x := d.Min.X; x < d.Max.X; x++ {
+				r, g, b, a := m.FloatAt(x, y)
+				sr, sg, sb, _ := smooth.FloatAt(x, y)
+				sharpen := func(v, s float64) float64 {
+					return math.Min(math.Max(v+amount*(v-s), 0), a)
+				}
+				dst.SetFloat(x, y, sharpen(r, sr), sharpen(g, sg), sharpen(b, sb), a)
+			}
+		}
+		return dst, nil
+	})
+}
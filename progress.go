@@ -0,0 +1,100 @@
+package bilateral
+
+import "sync"
+
+// Phase identifies a step of a filter's execution.
+type Phase int
+
+const (
+	// PhaseMinmax scans the image for its range bounds.
+	PhaseMinmax Phase = iota
+	// PhaseDownsampling accumulates the pixels into the grid.
+	PhaseDownsampling
+	// PhaseConvolution blurs the grid, one pass along one axis at a time.
+	PhaseConvolution
+	// PhaseSlicing interpolates the grid to compute the filtered pixels (ResultImage, ResultFloat, ResultMap).
+	PhaseSlicing
+)
+
+type (
+	// Progress reports the advancement of a filter's execution.
+	Progress struct {
+		Phase Phase
+		// Pass is the completed convolution pass, from 1 to Passes, during PhaseConvolution.
+		Pass   int
+		Passes int
+		// Tile is the filtered tile, from 1 to Tiles, for a Tiled filter.
+		Tile  int
+		Tiles int
+		// Fraction is the completed fraction of the phase, in [0, 1].
+		Fraction float64
+	}
+
+	// A ProgressFunc is called after each row (or column) of the pixel phases and each convolution pass.
+	// Calls are serialized but may come from the slicing goroutines, and must return quickly.
+	ProgressFunc func(p Progress)
+)
+
+// String implements fmt.Stringer interface.
+func (p Phase) String() string {
+	switch p {
+	case PhaseMinmax:
+		return "minmax"
+	case PhaseDownsampling:
+		return "downsampling"
+	case PhaseConvolution:
+		return "convolution"
+	case PhaseSlicing:
+		return "slicing"
+	default:
+		return "unknown"
+	}
+}
+
+// ProgressChannel returns a ProgressFunc sending the reports to ch.
+// Reports are dropped while ch is not ready to receive, so a slow reader never blocks the filter.
+func ProgressChannel(ch chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// Counter returns a func reporting the given phase's fraction each time one of n steps is completed.
+// It is safe for concurrent use. A nil ProgressFunc returns a no-op func.
+func (fn ProgressFunc) Counter(phase Phase, n int) func() {
+	if fn == nil {
+		return func() {}
+	}
+
+	var mu sync.Mutex
+	done := 0
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		done++
+		fn(Progress{Phase: phase, Fraction: float64(done) / float64(n)})
+	}
+}
+
+// pass reports the completion of the given convolution pass.
+func (fn ProgressFunc) pass(pass, passes int) {
+	if fn != nil {
+		fn(Progress{Phase: PhaseConvolution, Pass: pass, Passes: passes, Fraction: float64(pass) / float64(passes)})
+	}
+}
+
+// tile returns a ProgressFunc reporting the progress of the given tile to fn.
+func (fn ProgressFunc) tile(tile, tiles int) ProgressFunc {
+	if fn == nil {
+		return nil
+	}
+
+	return func(p Progress) {
+		p.Tile, p.Tiles = tile, tiles
+		fn(p)
+	}
+}
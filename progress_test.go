@@ -0,0 +1,57 @@
+package bilateral_test
+
+import (
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestFastBilateralProgress(t *testing.T) {
+	var reports []bilateral.Progress
+	filter := bilateral.Auto(images["base-gray"])
+	filter.Workers = 4
+	filter.OnProgress = func(p bilateral.Progress) {
+		reports = append(reports, p)
+	}
+	filter.Execute()
+	filter.ResultImage()
+
+	last := map[bilateral.Phase]bilateral.Progress{}
+	phase := bilateral.PhaseMinmax
+	for _, p := range reports {
+		if p.Phase < phase {
+			t.Fatalf("%s: expected: %s or later, actual: %s", "Phase", phase, p.Phase)
+		}
+		if p.Phase == phase && p.Fraction < last[phase].Fraction {
+			t.Fatalf("%s: expected: %f or more, actual: %f", phase, last[phase].Fraction, p.Fraction)
+		}
+		phase = p.Phase
+		last[phase] = p
+	}
+
+	for _, phase := range []bilateral.Phase{bilateral.PhaseMinmax, bilateral.PhaseDownsampling, bilateral.PhaseConvolution, bilateral.PhaseSlicing} {
+		if last[phase].Fraction != 1 {
+			t.Errorf("%s: expected: %f, actual: %f", phase, 1.0, last[phase].Fraction)
+		}
+	}
+	// Gray grid: 3 axes blurred twice by BinomialKernel
+	if p := last[bilateral.PhaseConvolution]; p.Pass != 6 || p.Passes != 6 {
+		t.Errorf("%s: expected: %d/%d, actual: %d/%d", "Passes", 6, 6, p.Pass, p.Passes)
+	}
+}
+
+func TestProgressChannel(t *testing.T) {
+	ch := make(chan bilateral.Progress, 1)
+	report := bilateral.ProgressChannel(ch)
+	report(bilateral.Progress{Phase: bilateral.PhaseMinmax, Fraction: 0.5})
+	report(bilateral.Progress{Phase: bilateral.PhaseMinmax, Fraction: 1}) // Dropped, the channel is full
+
+	if p := <-ch; p.Fraction != 0.5 {
+		t.Errorf("%s: expected: %f, actual: %f", "Fraction", 0.5, p.Fraction)
+	}
+	select {
+	case p := <-ch:
+		t.Errorf("%s: expected: %s, actual: %v", "Channel", "empty", p)
+	default:
+	}
+}
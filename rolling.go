@@ -0,0 +1,110 @@
+package bilateral
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// Default number of joint bilateral passes, after Zhang et al. "Rolling Guidance Filter".
+const defaultRollingIterations = 4
+
+// A RollingGuidance filter is a scale-aware smoothing filter.
+// It first removes the structures smaller than SigmaSpace with a Gaussian blur,
+// then iteratively recovers the large edges with joint bilateral passes
+// guided by the previous pass' result.
+type RollingGuidance struct {
+	Image      image.Image
+	SigmaRange float64
+	SigmaSpace float64
+	// Iterations is the number of joint bilateral passes.
+	Iterations int
+	result     *FloatImage
+}
+
+// NewRollingGuidance instanciates a new RollingGuidance filter running the given number of joint bilateral passes,
+// 4 when not positive.
+func NewRollingGuidance(m image.Image, sigmaSpace, sigmaRange float64, iterations int) *RollingGuidance {
+	if iterations <= 0 {
+		iterations = defaultRollingIterations
+	}
+	return &RollingGuidance{
+		Image:      m,
+		SigmaRange: sigmaRange,
+		SigmaSpace: sigmaSpace,
+		Iterations: iterations,
+	}
+}
+
+// Execute runs the small structures removal and the edges recovery.
+func (r *RollingGuidance) Execute() error {
+	m := ToFloatImage(r.Image)
+	guide := blur(m, r.SigmaSpace)
+	for i := 0; i < r.Iterations; i++ {
+		f := New(m, r.SigmaSpace, r.SigmaRange)
+		f.Guide = guide
+		if err := f.Execute(); err != nil {
+			return err
+		}
+		guide = f.ResultFloat()
+	}
+	r.result = guide
+	return nil
+}
+
+// ColorModel returns the Image's color model.
+func (r *RollingGuidance) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// Bounds implements image.Image interface.
+func (r *RollingGuidance) Bounds() image.Rectangle {
+	return r.Image.Bounds()
+}
+
+// At returns the filtered color at the given coordinates.
+func (r *RollingGuidance) At(x, y int) color.Color {
+	return util.RGBA8(r.result.FloatAt(x, y))
+}
+
+// ResultImage returns the filtered image.
+func (r *RollingGuidance) ResultImage() image.Image {
+	d := r.Image.Bounds()
+	dst := image.NewRGBA(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			dst.Set(x, y, r.At(x, y))
+		}
+	}
+	return dst
+}
+
+// ResultFloat returns the filtered image without quantization.
+func (r *RollingGuidance) ResultFloat() *FloatImage {
+	return r.result
+}
+
+// blur returns the given image blurred by a Gaussian of the given sigma in pixels, channel by channel.
+func blur(m *FloatImage, sigma float64) *FloatImage {
+	d := m.Bounds()
+	w, h := d.Dx(), d.Dy()
+
+	dst := NewFloatImage(d)
+	plane := make([]float64, w*h)
+	for c := 0; c < 4; c++ { // red, green, blue, alpha
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				plane[y*w+x] = m.Pix[m.PixOffset(d.Min.X+x, d.Min.Y+y)+c]
+			}
+		}
+
+		blurred := gaussianBlur(plane, w, h, sigma)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				dst.Pix[dst.PixOffset(d.Min.X+x, d.Min.Y+y)+c] = blurred[y*w+x]
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,86 @@
+package bilateral_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestJointBilateralSelfGuided(t *testing.T) {
+	mi := images["base"]
+
+	filter := bilateral.New(mi, 16, 0.1)
+	filter.Execute()
+	expected := filter.ResultFloat()
+
+	filter = bilateral.New(mi, 16, 0.1)
+	filter.Guide = mi
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	if !reflect.DeepEqual(filter.ResultFloat(), expected) {
+		t.Errorf("%s: expected: %v, actual: %v", "ResultFloat", "same as unguided", "different")
+	}
+}
+
+func TestRollingGuidance(t *testing.T) {
+	// Two flat halves covered by a fine checkerboard texture
+	m := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := 30
+			if x >= 16 {
+				v = 170
+			}
+			if (x+y)%2 == 0 {
+				v += 60
+			}
+			m.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+	// Differences between two texture cells and across the edge
+	texture := func(m *bilateral.FloatImage) float64 {
+		a, _, _, _ := m.FloatAt(6, 8)
+		b, _, _, _ := m.FloatAt(7, 8)
+		return math.Abs(a - b)
+	}
+	edge := func(m *bilateral.FloatImage) float64 {
+		a, _, _, _ := m.FloatAt(13, 8)
+		b, _, _, _ := m.FloatAt(18, 8)
+		return b - a
+	}
+
+	rolling := func(iterations int) *bilateral.FloatImage {
+		filter := bilateral.NewRollingGuidance(m, 3, 0.1, iterations)
+		if err := filter.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+		}
+		return filter.ResultFloat()
+	}
+	first := rolling(1)
+	result := rolling(4)
+
+	if d := texture(result); d > 0.01 {
+		t.Errorf("%s: expected: %v, actual: %v", "Texture", "< 0.01", d)
+	}
+
+	// The edge blurred by the first pass is recovered by the next ones
+	if e1, e4 := edge(first), edge(result); e4 < e1+0.05 {
+		t.Errorf("%s: expected: %v, actual: %v", "Edge", fmt.Sprintf("> %v", e1+0.05), e4)
+	}
+
+	// The passes converge, a fifth one barely changes the result
+	var change float64
+	for i, v := range rolling(5).Pix {
+		change = math.Max(change, math.Abs(float64(v-result.Pix[i])))
+	}
+	if change > 0.005 {
+		t.Errorf("%s: expected: %v, actual: %v", "Convergence", "< 0.005", change)
+	}
+}
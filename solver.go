@@ -0,0 +1,254 @@
+package bilateral
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+const (
+	// Default bilateral solver parameters, after Barron and Poole "The Fast Bilateral Solver".
+	defaultLambda           = 128
+	defaultSolverIterations = 100
+	defaultTolerance        = 1e-5
+	// Iterations of the grid bistochastization.
+	bistochasticIterations = 20
+)
+
+// ErrMapSize is returned when a map does not match the expected size.
+var ErrMapSize = errors.New("bilateral: invalid map size")
+
+type (
+	// A Solver is a bilateral solver (Barron and Poole "The Fast Bilateral Solver").
+	// It computes the smoothest map close to a target map, weighted by a confidence map,
+	// whose edges follow the edges of a reference image.
+	// The smoothness is measured in the bilateral grid of the reference, so it is solved on the grid's vertices.
+	Solver struct {
+		Reference  image.Image
+		SigmaRange float64
+		SigmaSpace float64
+		// Lambda is the smoothness weight relatively to the confidence.
+		Lambda float64
+		// Iterations is the maximum number of conjugate gradient iterations.
+		Iterations int
+		// Tolerance is the relative residual which stops the conjugate gradient.
+		Tolerance float64
+		grid      *solverGrid
+	}
+
+	// A solverGrid holds the occupied vertices of a bilateral grid.
+	solverGrid struct {
+		// Vertex of each pixel.
+		pixels []int
+		// Occupied neighbours of each vertex.
+		neighbours [][]int
+		// Grid dimensions.
+		dimension int
+		// Pixels' count of each vertex.
+		m []float64
+		// Bistochastization weights of each vertex.
+		n []float64
+	}
+)
+
+// NewSolver instanciates a new Solver.
+func NewSolver(reference image.Image, sigmaSpace, sigmaRange float64) *Solver {
+	return &Solver{
+		Reference:  reference,
+		SigmaRange: sigmaRange,
+		SigmaSpace: sigmaSpace,
+		Lambda:     defaultLambda,
+		Iterations: defaultSolverIterations,
+		Tolerance:  defaultTolerance,
+	}
+}
+
+// Solve returns the solved map of the given target and confidence maps.
+// Maps hold one value per pixel of the reference, in rows order. A nil confidence is a full confidence.
+// The reference's grid is computed on the first call and reused by the next ones.
+// It returns an error wrapping ErrInvalidOption if the sigma values are not positive and finite.
+func (s *Solver) Solve(target, confidence []float64) ([]float64, error) {
+	if !positive(s.SigmaSpace) || !positive(s.SigmaRange) {
+		return nil, fmt.Errorf("%w: solver sigmas must be positive and finite, got %v and %v", ErrInvalidOption, s.SigmaSpace, s.SigmaRange)
+	}
+
+	d := s.Reference.Bounds()
+	if len(target) != d.Dx()*d.Dy() || (confidence != nil && len(confidence) != len(target)) {
+		return nil, ErrMapSize
+	}
+	if s.grid == nil {
+		s.grid = newSolverGrid(s.Reference, s.SigmaSpace, s.SigmaRange)
+	}
+	l := s.grid
+
+	// Splat the data terms
+	c := make([]float64, len(l.m))
+	ct := make([]float64, len(l.m))
+	for i, v := range l.pixels {
+		w := 1.0
+		if confidence != nil {
+			w = confidence[i]
+		}
+		c[v] += w
+		ct[v] += w * target[i]
+	}
+
+	// A = lambda * (Dm - Dn B Dn) + diag(S c), b = S (c * t)
+	apply := func(dst, y []float64) {
+		ny := make([]float64, len(y))
+		for v := range y {
+			ny[v] = l.n[v] * y[v]
+		}
+		for v := range y {
+			dst[v] = s.Lambda*(l.m[v]*y[v]-l.n[v]*l.blur(ny, v)) + c[v]*y[v]
+		}
+	}
+	diagonal := make([]float64, len(l.m))
+	for v := range diagonal {
+		diagonal[v] = s.Lambda*(l.m[v]-l.n[v]*l.n[v]*float64(2*l.dimension)) + c[v]
+	}
+
+	y := make([]float64, len(l.m))
+	for v := range y {
+		if c[v] > 0 {
+			y[v] = ct[v] / c[v]
+		}
+	}
+	conjugateGradient(apply, ct, diagonal, y, s.Iterations, s.Tolerance)
+
+	// Slice
+	solved := make([]float64, len(target))
+	for i, v := range l.pixels {
+		solved[i] = y[v]
+	}
+	return solved, nil
+}
+
+// newSolverGrid splats the reference's pixels into the vertices of its bilateral grid and bistochastizes it.
+func newSolverGrid(reference image.Image, sigmaSpace, sigmaRange float64) *solverGrid {
+	f := New(reference, sigmaSpace, sigmaRange)
+	f.minmaxOnce.Do(f.minmax)
+	f.resize()
+
+	strides := make([]int, f.dimension)
+	strides[0] = 1
+	for n := 1; n < f.dimension; n++ {
+		strides[n] = strides[n-1] * f.size[n-1]
+	}
+
+	l := &solverGrid{dimension: f.dimension}
+	vertices := map[int]int{} // grid offset -> vertex
+	var keys []int            // vertex -> grid offset
+	d := reference.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			gx, gy := f.spaceCoord(x, y)
+			rgb, _ := pixel(reference, x, y)
+
+			key := (int(gx+0.5)+paddingS)*strides[0] + (int(gy+0.5)+paddingS)*strides[1]
+			for z := 0; z < f.dimension-2; z++ {
+				key += (int(f.rangeCoord(z, rgb[z])+0.5) + paddingR) * strides[2+z]
+			}
+
+			v, ok := vertices[key]
+			if !ok {
+				v = len(l.m)
+				vertices[key] = v
+				keys = append(keys, key)
+				l.m = append(l.m, 0)
+			}
+			l.m[v]++
+			l.pixels = append(l.pixels, v)
+		}
+	}
+
+	l.neighbours = make([][]int, len(l.m))
+	for v, key := range keys {
+		for _, stride := range strides { // The padding keeps the neighbours' keys in the grid
+			for _, k := range []int{key - stride, key + stride} {
+				if nv, ok := vertices[k]; ok {
+					l.neighbours[v] = append(l.neighbours[v], nv)
+				}
+			}
+		}
+	}
+
+	l.bistochastize()
+	return l
+}
+
+// blur returns the [1 2 1] blur of the vertex v along all the grid axes.
+func (l *solverGrid) blur(values []float64, v int) float64 {
+	sum := float64(2*l.dimension) * values[v]
+	for _, nv := range l.neighbours[v] {
+		sum += values[nv]
+	}
+	return sum
+}
+
+// bistochastize computes the weights n such that n * blur(n) approximates the pixels' counts m.
+func (l *solverGrid) bistochastize() {
+	l.n = make([]float64, len(l.m))
+	for v := range l.n {
+		l.n[v] = 1
+	}
+
+	next := make([]float64, len(l.n))
+	for i := 0; i < bistochasticIterations; i++ {
+		for v := range next {
+			next[v] = math.Sqrt(l.n[v] * l.m[v] / l.blur(l.n, v))
+		}
+		l.n, next = next, l.n
+	}
+}
+
+// conjugateGradient solves A x = b with a Jacobi preconditioned conjugate gradient, starting from x.
+func conjugateGradient(apply func(dst, x []float64), b, diagonal, x []float64, iterations int, tolerance float64) {
+	dot := func(a, b []float64) (sum float64) {
+		for i := range a {
+			sum += a[i] * b[i]
+		}
+		return
+	}
+	precondition := func(dst, r []float64) {
+		for i := range r {
+			if diagonal[i] > 0 {
+				dst[i] = r[i] / diagonal[i]
+			} else {
+				dst[i] = r[i]
+			}
+		}
+	}
+
+	n := len(b)
+	r := make([]float64, n)
+	z := make([]float64, n)
+	p := make([]float64, n)
+	ap := make([]float64, n)
+
+	apply(r, x)
+	for i := range r {
+		r[i] = b[i] - r[i]
+	}
+	precondition(z, r)
+	copy(p, z)
+	rz := dot(r, z)
+	threshold := tolerance * tolerance * dot(b, b)
+
+	for k := 0; k < iterations && dot(r, r) > threshold; k++ {
+		apply(ap, p)
+		alpha := rz / dot(p, ap)
+		for i := range x {
+			x[i] += alpha * p[i]
+			r[i] -= alpha * ap[i]
+		}
+
+		precondition(z, r)
+		next := dot(r, z)
+		for i := range p {
+			p[i] = z[i] + next/rz*p[i]
+		}
+		rz = next
+	}
+}
@@ -0,0 +1,75 @@
+package bilateral_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestSolver(t *testing.T) {
+	// Reference: dark left half and bright right half
+	reference := image.NewGray(image.Rect(0, 0, 32, 32))
+	target := make([]float64, 32*32)
+	confidence := make([]float64, 32*32)
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			i := y*32 + x
+			reference.SetGray(x, y, color.Gray{Y: 40})
+			target[i] = 0.2
+			if x >= 16 {
+				reference.SetGray(x, y, color.Gray{Y: 200})
+				target[i] = 0.8
+			}
+
+			// Noisy target with holes
+			target[i] += 0.05 * float64((x*7+y*13)%5-2)
+			confidence[i] = 1
+			if x%8 == 3 && y%8 == 3 {
+				target[i] = 0
+				confidence[i] = 0
+			}
+		}
+	}
+
+	solver := bilateral.NewSolver(reference, 1, 0.1)
+	solved, err := solver.Solve(target, confidence)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Solve", nil, err)
+	}
+
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			expected := 0.2
+			if x >= 16 {
+				expected = 0.8
+			}
+			if v := solved[y*32+x]; math.Abs(v-expected) > 0.02 {
+				t.Fatalf("%s: expected: %v, actual: %v", "Solve", expected, v)
+			}
+		}
+	}
+
+	if _, err = solver.Solve(target[1:], nil); err != bilateral.ErrMapSize {
+		t.Errorf("%s: expected: %v, actual: %v", "Solve", bilateral.ErrMapSize, err)
+	}
+}
+
+func TestSolverInvalidSigmas(t *testing.T) {
+	reference := image.NewGray(image.Rect(0, 0, 8, 8))
+	target := make([]float64, 8*8)
+
+	for name, solver := range map[string]*bilateral.Solver{
+		"Zero sigma space":     bilateral.NewSolver(reference, 0, 0.1),
+		"Negative sigma range": bilateral.NewSolver(reference, 1, -0.1),
+		"NaN sigma range":      bilateral.NewSolver(reference, 1, math.NaN()),
+		"Infinite sigma space": bilateral.NewSolver(reference, math.Inf(1), 0.1),
+	} {
+		if _, err := solver.Solve(target, nil); !errors.Is(err, bilateral.ErrInvalidOption) {
+			t.Errorf("%s: expected: %v, actual: %v", name, bilateral.ErrInvalidOption, err)
+		}
+	}
+}
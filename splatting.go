@@ -0,0 +1,61 @@
+package bilateral
+
+import (
+	"gonum.org/v1/gonum/mat"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// Splatting defines how the pixels are accumulated into the grid.
+type Splatting int
+
+const (
+	// Nearest accumulates each pixel into its nearest grid cell (default).
+	Nearest Splatting = iota
+	// Tent spreads each pixel over its neighbouring cells with multi-linear weights,
+	// symmetrically with the Linear slicing. It reduces the banding in smooth gradients.
+	Tent
+)
+
+// String implements fmt.Stringer interface.
+func (s Splatting) String() string {
+	switch s {
+	case Nearest:
+		return "nearest"
+	case Tent:
+		return "tent"
+	default:
+		return "unknown"
+	}
+}
+
+// tentSplat accumulates the colors weighted by w into the cells surrounding the given grid coordinates.
+func (f *FastBilateral) tentSplat(coords []float64, colors *mat.VecDense, w float64) {
+	index := make([]int, f.dimension)
+	alpha := make([]float64, f.dimension)
+	for n, c := range coords {
+		index[n] = int(c)
+		alpha[n] = c - float64(index[n])
+	}
+
+	off := make([]int, f.dimension)
+	for i := 0; i < 1<<uint(f.dimension); i++ {
+		weight := w
+		for n := range off {
+			if i&(1<<uint(n)) == 0 {
+				off[n] = index[n]
+				weight *= 1.0 - alpha[n]
+			} else {
+				off[n] = util.Clamp(0, f.size[n]-1, index[n]+1)
+				weight *= alpha[n]
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+
+		v := f.grid.At(off...)
+		v.colors.AddScaledVec(v.colors, weight, colors)
+		v.threshold += weight
+	}
+}
@@ -0,0 +1,42 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestFastBilateralTent(t *testing.T) {
+	// Smooth gradient, left untouched by an ideal filter
+	gradient := func(x int) float64 { return 0.3 + 0.3*float64(x)/96 }
+	mi := image.NewGray16(image.Rect(0, 0, 96, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 96; x++ {
+			mi.SetGray16(x, y, color.Gray16{Y: uint16(gradient(x) * 65535)})
+		}
+	}
+
+	// Mean absolute error along a row, away from the image borders
+	banding := func(m image.Image) float64 {
+		var e float64
+		for x := 16; x < 80; x++ {
+			r, _, _, _ := m.At(x, 4).RGBA()
+			e += math.Abs(float64(r>>8) - gradient(x)*255)
+		}
+		return e / 64
+	}
+
+	nearest := bilateral.New(mi, 6, 0.05)
+	nearest.Execute()
+
+	tent := bilateral.New(mi, 6, 0.05)
+	tent.Splatting = bilateral.Tent
+	tent.Execute()
+
+	if n, te := banding(nearest.ResultImage()), banding(tent.ResultImage()); te >= n {
+		t.Errorf("%s: expected lower than %f, actual: %f", "Tent banding", n, te)
+	}
+}
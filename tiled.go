@@ -0,0 +1,255 @@
+package bilateral
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+const (
+	// Default tile size (without borders).
+	defaultTileSize = 1024
+	// Border width added to the convolution support, in grid cells.
+	// It covers the downsampling and the interpolation supports.
+	borderMargin = 2
+)
+
+type (
+	// A TileReader provides the pixels of an image one tile at a time.
+	TileReader interface {
+		// Bounds returns the bounds of the whole image.
+		Bounds() image.Rectangle
+		// ReadTile returns the pixels within r, in the whole image coordinates.
+		ReadTile(r image.Rectangle) (image.Image, error)
+	}
+
+	// A TileWriter receives the filtered tiles.
+	TileWriter interface {
+		// WriteTile stores the given tile, located by its bounds in the whole image.
+		WriteTile(m image.Image) error
+	}
+
+	// A Tiled filter runs a FastBilateral on overlapping tiles, so very large images
+	// never hold more than a tile's grid and result in memory.
+	// All tiles share the range bounds and the spatial sampling lattice of the whole image,
+	// so the tiles are stitched seamlessly.
+	// Pixels of zero confidence farther than Border from any contributing pixel are filled in
+	// from their tile only, and may therefore differ from a FastBilateral over the whole image.
+	Tiled struct {
+		Reader     TileReader
+		SigmaRange float64
+		SigmaSpace float64
+		// Guide, when set, is the image whose colors define the range coordinates (joint bilateral filtering).
+		// It must cover the Reader's bounds and is read in the whole image coordinates, like Mask and Confidence.
+		Guide image.Image
+		// Mask, when set, restricts the filtering to the pixels where its alpha is not zero.
+		Mask image.Image
+		// Confidence, when set, scales the contribution of each pixel to the grids by its gray level clamped to [0, 1].
+		Confidence image.Image
+		// SigmaRanges, when set, holds the range sigma of each colour channel (see FastBilateral).
+		SigmaRanges []float64
+		// SigmaSpaceY, when positive, is the spatial sigma along the vertical axis.
+		SigmaSpaceY float64
+		// Rotation is the angle in radians between the image axes and the spatial sigmas' axes.
+		Rotation float64
+		// TileSize is the width and height of the written tiles.
+		TileSize int
+		// Kernels holds the blur kernel of each grid axis.
+		Kernels []Kernel
+		// RangeKernel, when set, is the kernel of the colour depths missing from Kernels.
+		RangeKernel Kernel
+		// Splatting defines how the pixels are accumulated into the grids.
+		Splatting Splatting
+		// Interpolation defines how the grids are sliced.
+		Interpolation Interpolation
+		// ColorSpace defines the colour space of the range axes, RGB by default.
+		ColorSpace ColorSpace
+		// Workers, when greater than 1, is the number of goroutines slicing each tile.
+		Workers int
+		// Border is the width of the context read around each tile.
+		// It defaults to the spatial kernels' support plus 2 cells (4 times the largest spatial sigma for BinomialKernel).
+		Border int
+		// Percentiles, when enabled, computes the range bounds from the given percentiles.
+		Percentiles Percentiles
+		// Output defines the image type of the written tiles, OutputRGBA by default.
+		Output Output
+		// OnProgress, when set, is called with the progress of the range bounds scan and of each tile's phases.
+		OnProgress ProgressFunc
+		// MaxMemory, when positive, caps the memory in bytes allocated by each tile's grids (see Footprint).
+		// The tiles are never coarsened since they share the spatial sampling, lower TileSize instead.
+		MaxMemory int64
+		bounds    *rangeBounds
+		auto      bool
+	}
+
+	// ImageTileReader adapts an image.Image to the TileReader interface.
+	ImageTileReader struct {
+		image.Image
+	}
+
+	// ImageTileWriter adapts a draw.Image to the TileWriter interface.
+	ImageTileWriter struct {
+		draw.Image
+	}
+
+	// region restricts the bounds of an image.
+	region struct {
+		image.Image
+		r image.Rectangle
+	}
+)
+
+// AutoTiled instanciates a new Tiled filter with automatic sigma values.
+func AutoTiled(r TileReader) *Tiled {
+	t := NewTiled(r, 16, 0.1)
+	t.auto = true
+	return t
+}
+
+// NewTiled instanciates a new Tiled filter.
+func NewTiled(r TileReader, sigmaSpace, sigmaRange float64) *Tiled {
+	return &Tiled{
+		Reader:     r,
+		SigmaRange: sigmaRange,
+		SigmaSpace: sigmaSpace,
+		TileSize:   defaultTileSize,
+	}
+}
+
+// Execute filters each tile and streams it to the given writer.
+// The image is read twice: once to compute the range bounds and once to filter the tiles.
+// With Percentiles, the range bounds take two reads.
+func (t *Tiled) Execute(w TileWriter) error {
+	if err := t.minmax(); err != nil {
+		return err
+	}
+
+	d := t.Reader.Bounds()
+	origin := d.Min
+	border := t.border()
+	tiles := t.tiles()
+	for i, core := range tiles {
+		m, err := t.Reader.ReadTile(core.Inset(-border).Intersect(d))
+		if err != nil {
+			return err
+		}
+
+		f := New(m, t.SigmaSpace, t.SigmaRange)
+		f.Guide = t.Guide
+		f.Mask = t.Mask
+		f.Confidence = t.Confidence
+		f.SigmaRanges = t.SigmaRanges
+		f.Kernels = t.Kernels
+		f.RangeKernel = t.RangeKernel
+		f.Splatting = t.Splatting
+		f.Interpolation = t.Interpolation
+		f.ColorSpace = t.ColorSpace
+		f.Workers = t.Workers
+		f.SigmaSpaceY = t.SigmaSpaceY
+		f.Rotation = t.Rotation
+		f.Output = t.Output
+		f.OnProgress = t.OnProgress.tile(i+1, len(tiles))
+		f.MaxMemory = t.MaxMemory
+		f.origin = &origin
+		f.auto = t.auto
+		f.minmaxOnce.Do(func() {
+			f.setBounds(t.bounds)
+		})
+		if err = f.Execute(); err != nil {
+			return err
+		}
+
+		if err = w.WriteTile(f.resultImage(core)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tiled) minmax() error {
+	if t.bounds != nil {
+		return nil
+	}
+
+	bounds := newRangeBounds(3, t.Percentiles) // red, green, blue
+	bounds.space = t.ColorSpace
+	tiles := t.tiles()
+	step := t.OnProgress.Counter(PhaseMinmax, bounds.passes()*len(tiles))
+	for pass := 0; pass < bounds.passes(); pass++ {
+		if pass > 0 {
+			bounds.next()
+		}
+		for _, core := range tiles {
+			m, err := t.reference(core)
+			if err != nil {
+				return err
+			}
+			bounds.scan(m, func(x, y int) bool {
+				return coverage(t.Mask, x, y) > 0 && confidence(t.Confidence, x, y) > 0
+			})
+			step()
+		}
+	}
+	t.bounds = bounds
+	return nil
+}
+
+// reference returns the pixels within r defining the range coordinates, read from Guide when set.
+func (t *Tiled) reference(r image.Rectangle) (image.Image, error) {
+	if t.Guide != nil {
+		return &region{Image: t.Guide, r: r}, nil
+	}
+	return t.Reader.ReadTile(r)
+}
+
+func (t *Tiled) border() int {
+	if t.Border > 0 {
+		return t.Border
+	}
+	var support int
+	for axis := 0; axis < 2; axis++ { // x, y
+		k := kernel(t.Kernels, axis)
+		if s := k.Radius() * k.Iterations(); s > support {
+			support = s
+		}
+	}
+
+	support += borderMargin
+	if t.Interpolation == Cubic {
+		support++ // Catmull-Rom outer taps
+	}
+	return int(float64(support)*math.Max(t.SigmaSpace, t.SigmaSpaceY) + 0.5)
+}
+
+// tiles returns the tiles' locations, without borders.
+func (t *Tiled) tiles() []image.Rectangle {
+	size := t.TileSize
+	if size <= 0 {
+		size = defaultTileSize
+	}
+
+	d := t.Reader.Bounds()
+	var tiles []image.Rectangle
+	for y := d.Min.Y; y < d.Max.Y; y += size {
+		for x := d.Min.X; x < d.Max.X; x += size {
+			tiles = append(tiles, image.Rect(x, y, x+size, y+size).Intersect(d))
+		}
+	}
+	return tiles
+}
+
+// ReadTile implements TileReader interface.
+func (r ImageTileReader) ReadTile(rect image.Rectangle) (image.Image, error) {
+	return &region{Image: r.Image, r: rect}, nil
+}
+
+// WriteTile implements TileWriter interface.
+func (w ImageTileWriter) WriteTile(m image.Image) error {
+	draw.Draw(w.Image, m.Bounds(), m, m.Bounds().Min, draw.Src)
+	return nil
+}
+
+// Bounds implements image.Image interface.
+func (r *region) Bounds() image.Rectangle {
+	return r.r
+}
@@ -0,0 +1,189 @@
+package bilateral_test
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestTiled(t *testing.T) {
+	mi := images["base-gray"]
+
+	filter := bilateral.Auto(mi)
+	filter.SigmaSpace = 1
+	filter.Execute()
+	expected := filter.ResultImage().(*image.RGBA)
+
+	actual := image.NewRGBA(mi.Bounds())
+	tiled := bilateral.AutoTiled(bilateral.ImageTileReader{Image: mi})
+	tiled.SigmaSpace = 1
+	tiled.TileSize = 5
+	if err := tiled.Execute(bilateral.ImageTileWriter{Image: actual}); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	for i := range expected.Pix {
+		if diff := int(expected.Pix[i]) - int(actual.Pix[i]); diff < -1 || diff > 1 {
+			t.Fatalf("%s[%d]: expected: %d, actual: %d", "Pix", i, expected.Pix[i], actual.Pix[i])
+		}
+	}
+}
+
+func TestTiledOptions(t *testing.T) {
+	mi := images["base"]
+	d := mi.Bounds()
+
+	// Left columns out of the mask, a band of half confidence and a guide with a vertical edge
+	mask := image.NewAlpha(d)
+	confidence := bilateral.NewFloatMap(d)
+	guide := image.NewRGBA(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			guide.Set(x, y, color.RGBA{R: 10, G: 40, B: 20, A: 255})
+			if x >= 10 {
+				guide.Set(x, y, color.RGBA{R: 50, G: 200, B: 100, A: 255})
+			}
+			if x >= 3 {
+				mask.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+			confidence.SetFloat(x, y, 1)
+			if y >= 8 && y < 12 {
+				confidence.SetFloat(x, y, 0.5)
+			}
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		guide       image.Image
+		mask        image.Image
+		confidence  image.Image
+		colorSpace  bilateral.ColorSpace
+		percentiles bilateral.Percentiles
+	}{
+		"Guide":       {guide: guide},
+		"Mask":        {mask: mask},
+		"Confidence":  {confidence: confidence},
+		"ColorSpace":  {colorSpace: bilateral.Lab},
+		"Percentiles": {percentiles: bilateral.Percentiles{Low: 0.1, High: 0.9}},
+	} {
+		filter := bilateral.New(mi, 4, 0.4)
+		filter.Guide = tc.guide
+		filter.Mask = tc.mask
+		filter.Confidence = tc.confidence
+		filter.ColorSpace = tc.colorSpace
+		filter.Percentiles = tc.percentiles
+		if err := filter.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", name, nil, err)
+		}
+		expected := filter.ResultImage().(*image.RGBA)
+
+		actual := image.NewRGBA(d)
+		tiled := bilateral.NewTiled(bilateral.ImageTileReader{Image: mi}, 4, 0.4)
+		tiled.Guide = tc.guide
+		tiled.Mask = tc.mask
+		tiled.Confidence = tc.confidence
+		tiled.ColorSpace = tc.colorSpace
+		tiled.Percentiles = tc.percentiles
+		tiled.Workers = 4
+		tiled.TileSize = 10
+		if err := tiled.Execute(bilateral.ImageTileWriter{Image: actual}); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", name, nil, err)
+		}
+
+		for i := range expected.Pix {
+			if diff := int(expected.Pix[i]) - int(actual.Pix[i]); diff < -1 || diff > 1 {
+				t.Fatalf("%s[%d]: expected: %d, actual: %d", name, i, expected.Pix[i], actual.Pix[i])
+			}
+		}
+	}
+}
+
+// rgba64Writer checks the type of the written tiles.
+type rgba64Writer struct {
+	*image.RGBA64
+}
+
+func (w rgba64Writer) WriteTile(m image.Image) error {
+	tile, ok := m.(*image.RGBA64)
+	if !ok {
+		return fmt.Errorf("unexpected tile type %T", m)
+	}
+	draw.Draw(w.RGBA64, tile.Bounds(), tile, tile.Bounds().Min, draw.Src)
+	return nil
+}
+
+func TestTiledOutput(t *testing.T) {
+	mi := images["base-gray"]
+
+	filter := bilateral.New(mi, 1, 0.1)
+	filter.Output = bilateral.OutputRGBA64
+	filter.Execute()
+	expected := filter.ResultImage().(*image.RGBA64)
+
+	actual := rgba64Writer{image.NewRGBA64(mi.Bounds())}
+	tiled := bilateral.NewTiled(bilateral.ImageTileReader{Image: mi}, 1, 0.1)
+	tiled.Output = bilateral.OutputRGBA64
+	tiled.TileSize = 5
+	if err := tiled.Execute(actual); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	for i := 0; i < len(expected.Pix); i += 2 {
+		e := int(expected.Pix[i])<<8 | int(expected.Pix[i+1])
+		a := int(actual.Pix[i])<<8 | int(actual.Pix[i+1])
+		if diff := e - a; diff < -256 || diff > 256 {
+			t.Fatalf("%s[%d]: expected: %d, actual: %d", "Pix", i, e, a)
+		}
+	}
+}
+
+func TestTiledProgress(t *testing.T) {
+	mi := images["base-gray"]
+
+	var reports []bilateral.Progress
+	tiled := bilateral.NewTiled(bilateral.ImageTileReader{Image: mi}, 1, 0.1)
+	tiled.TileSize = 5
+	tiled.OnProgress = func(p bilateral.Progress) {
+		reports = append(reports, p)
+	}
+	if err := tiled.Execute(bilateral.ImageTileWriter{Image: image.NewRGBA(mi.Bounds())}); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	d := mi.Bounds()
+	tiles := ((d.Dx() + 4) / 5) * ((d.Dy() + 4) / 5)
+	var minmax float64
+	seen := map[int]bool{}
+	for _, p := range reports {
+		if p.Phase == bilateral.PhaseMinmax {
+			minmax = p.Fraction
+			continue
+		}
+		if p.Tiles != tiles {
+			t.Fatalf("%s: expected: %v, actual: %v", "Tiles", tiles, p.Tiles)
+		}
+		seen[p.Tile] = true
+	}
+	if minmax != 1 {
+		t.Errorf("%s: expected: %v, actual: %v", "Minmax", 1, minmax)
+	}
+	if len(seen) != tiles {
+		t.Errorf("%s: expected: %v, actual: %v", "Reported tiles", tiles, len(seen))
+	}
+}
+
+func TestTiledMaxMemory(t *testing.T) {
+	mi := images["base-gray"]
+
+	tiled := bilateral.NewTiled(bilateral.ImageTileReader{Image: mi}, 1, 0.1)
+	tiled.MaxMemory = 1
+	err := tiled.Execute(bilateral.ImageTileWriter{Image: image.NewRGBA(mi.Bounds())})
+	if !errors.Is(err, bilateral.ErrMemoryLimit) {
+		t.Errorf("%s: expected: %v, actual: %v", "Execute", bilateral.ErrMemoryLimit, err)
+	}
+}
@@ -1,27 +1,14 @@
 package bilateral
 
-const maxrange = 65535
-
-func clamp(min, max, v int) int {
-	if v < min {
-		v = 0
-	}
-	if v > max {
-		v = max
+// scale returns a copy of the values multiplied by alpha.
+func scale(alpha float64, values []float64) []float64 {
+	if values == nil {
+		return nil
 	}
-	return v
-}
 
-func mul(size ...int) (n int) {
-	n = 1
-	for _, v := range size {
-		if v != 0 {
-			n *= v
-		}
+	scaled := make([]float64, len(values))
+	for i, v := range values {
+		scaled[i] = alpha * v
 	}
-	return
-}
-
-func fcolor(v uint32) float64 {
-	return float64(v) / maxrange
+	return scaled
 }
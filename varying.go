@@ -0,0 +1,160 @@
+package bilateral
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// Default number of grids of a Varying filter.
+const defaultVaryingLevels = 4
+
+// A Varying filter is a bilateral filter whose range sigma varies per pixel, following a sigma map.
+// It runs FastBilateral filters at several range sigmas, sharing the range bounds,
+// and interpolates per pixel between the two grids surrounding the pixel's sigma at slice time.
+type Varying struct {
+	Image      image.Image
+	SigmaSpace float64
+	// SigmaMap holds the range sigma of each pixel as its gray level, bounded below by 0.05.
+	// FloatMap values are read without quantization.
+	SigmaMap image.Image
+	// Strength, when set, blends the original and the filtered colors by its gray level:
+	// 0 keeps the original colors and 1 the filtered ones.
+	Strength image.Image
+	// Levels is the number of grids, at range sigmas spaced geometrically between the map's extrema.
+	// 4 when not positive.
+	Levels  int
+	sigmas  []float64
+	filters []*FastBilateral
+}
+
+// NewVarying instanciates a new Varying filter.
+func NewVarying(m image.Image, sigmaSpace float64, sigmaMap image.Image) *Varying {
+	return &Varying{
+		Image:      m,
+		SigmaSpace: sigmaSpace,
+		SigmaMap:   sigmaMap,
+		Levels:     defaultVaryingLevels,
+	}
+}
+
+// Execute runs the bilateral filters of each level.
+func (v *Varying) Execute() error {
+	d := v.Image.Bounds()
+	min, max := math.Inf(1), math.Inf(-1)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			sigma := v.sigma(x, y)
+			min = math.Min(min, sigma)
+			max = math.Max(max, sigma)
+		}
+	}
+
+	levels := v.Levels
+	if levels <= 0 {
+		levels = defaultVaryingLevels
+	}
+	if min == max {
+		levels = 1
+	}
+
+	v.sigmas = make([]float64, levels)
+	for i := range v.sigmas {
+		v.sigmas[i] = min
+		if levels > 1 {
+			v.sigmas[i] = min * math.Pow(max/min, float64(i)/float64(levels-1))
+		}
+	}
+
+	bounds := newRangeBounds(3, Percentiles{}) // red, green, blue
+	bounds.scan(v.Image, nil)
+
+	v.filters = make([]*FastBilateral, levels)
+	for i, sigma := range v.sigmas {
+		f := New(v.Image, v.SigmaSpace, sigma)
+		f.minmaxOnce.Do(func() {
+			f.setBounds(bounds)
+		})
+		if err := f.Execute(); err != nil {
+			return err
+		}
+		v.filters[i] = f
+	}
+	return nil
+}
+
+// ColorModel returns the Image's color model.
+func (v *Varying) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// Bounds implements image.Image interface.
+func (v *Varying) Bounds() image.Rectangle {
+	return v.Image.Bounds()
+}
+
+// At computes the interpolation and returns the filtered color at the given coordinates.
+func (v *Varying) At(x, y int) color.Color {
+	rgb, a := v.filtered(x, y)
+	return util.RGBA8(rgb[c1], rgb[c2], rgb[c3], a)
+}
+
+// ResultImage computes the interpolation and returns the filtered image.
+func (v *Varying) ResultImage() image.Image {
+	d := v.Image.Bounds()
+	dst := image.NewRGBA(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			dst.Set(x, y, v.At(x, y))
+		}
+	}
+	return dst
+}
+
+// ResultFloat computes the interpolation and returns the filtered image without quantization.
+func (v *Varying) ResultFloat() *FloatImage {
+	d := v.Image.Bounds()
+	dst := NewFloatImage(d)
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			rgb, a := v.filtered(x, y)
+			dst.SetFloat(x, y, rgb[c1], rgb[c2], rgb[c3], a)
+		}
+	}
+	return dst
+}
+
+// filtered interpolates the filtered colors of the two levels surrounding the sigma of the pixel (x, y).
+func (v *Varying) filtered(x, y int) ([3]float64, float64) {
+	sigma := util.Clampf(v.sigmas[0], v.sigmas[len(v.sigmas)-1], v.sigma(x, y))
+	i := 0
+	for i < len(v.sigmas)-2 && sigma > v.sigmas[i+1] {
+		i++
+	}
+
+	rgb, a := v.filters[i].filtered(x, y)
+	if len(v.sigmas) > 1 {
+		// Geometric interpolation between the levels
+		t := math.Log(sigma/v.sigmas[i]) / math.Log(v.sigmas[i+1]/v.sigmas[i])
+		next, _ := v.filters[i+1].filtered(x, y)
+		for c := range rgb {
+			rgb[c] = (1-t)*rgb[c] + t*next[c]
+		}
+	}
+
+	if v.Strength != nil {
+		s := grayLevel(v.Strength, x, y)
+		original, _ := pixel(v.Image, x, y)
+		for c := range rgb {
+			rgb[c] = s*rgb[c] + (1-s)*original[c]
+		}
+	}
+	return rgb, a
+}
+
+// sigma returns the range sigma of the pixel (x, y).
+func (v *Varying) sigma(x, y int) float64 {
+	return math.Max(grayLevel(v.SigmaMap, x, y), minSigmaRange)
+}
@@ -0,0 +1,88 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestVaryingUniform(t *testing.T) {
+	mi := images["base-gray"]
+
+	filter := bilateral.New(mi, 4, 0.2)
+	filter.Execute()
+	expected := filter.ResultFloat()
+
+	varying := bilateral.NewVarying(mi, 4, image.NewUniform(color.Gray16{Y: 0.2 * 0xffff}))
+	if err := varying.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	// Same sigma up to the map's quantization
+	actual := varying.ResultFloat()
+	if !reflect.DeepEqual(actual.Bounds(), expected.Bounds()) {
+		t.Fatalf("%s: expected: %v, actual: %v", "Bounds", expected.Bounds(), actual.Bounds())
+	}
+	for i, v := range expected.Pix {
+		if d := actual.Pix[i] - v; d > 1e-3 || d < -1e-3 {
+			t.Fatalf("%s: expected: %v, actual: %v", "ResultFloat", v, actual.Pix[i])
+		}
+	}
+}
+
+func TestVarying(t *testing.T) {
+	// Flat checkerboard texture, with a range sigma ramping from 0.05 on the left to 0.5 on the right
+	m := image.NewGray(image.Rect(0, 0, 32, 32))
+	sigmas := bilateral.NewFloatMap(m.Bounds())
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := uint8(100)
+			if (x+y)%2 == 0 {
+				v = 140
+			}
+			m.SetGray(x, y, color.Gray{Y: v})
+
+			sigmas.SetFloat(x, y, float32(0.05+0.45*float64(x)/31))
+		}
+	}
+
+	varying := bilateral.NewVarying(m, 2, sigmas)
+	if err := varying.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+	result := varying.ResultFloat()
+
+	contrast := func(x, y int) float64 {
+		a, _, _, _ := result.FloatAt(x, y)
+		b, _, _, _ := result.FloatAt(x+1, y)
+		return math.Abs(a - b)
+	}
+	if c := contrast(0, 8); c < 0.1 {
+		t.Errorf("%s: expected: %v, actual: %v", "Kept texture", "> 0.1", c)
+	}
+	if c := contrast(29, 8); c > 0.005 {
+		t.Errorf("%s: expected: %v, actual: %v", "Smoothed texture", "< 0.005", c)
+	}
+
+	// The sigmas between the levels are interpolated:
+	// the texture fades smoothly along the ramp instead of by one step per level
+	steps := map[int]bool{}
+	for x := 0; x < 30; x++ {
+		if contrast(x+1, 8) > contrast(x, 8)+1e-6 {
+			t.Errorf("%s(%d): expected: <= %v, actual: %v", "Contrast", x+1, contrast(x, 8), contrast(x+1, 8))
+		}
+		steps[int(contrast(x, 8)*1000+0.5)] = true
+	}
+	if len(steps) <= varying.Levels {
+		t.Errorf("%s: expected: > %d, actual: %d", "Contrast steps", varying.Levels, len(steps))
+	}
+
+	// Zero strength keeps the original image
+	varying.Strength = image.NewUniform(color.Black)
+	if c := varying.At(25, 8); c != color.RGBAModel.Convert(m.At(25, 8)) {
+		t.Errorf("%s: expected: %v, actual: %v", "Strength", m.At(25, 8), c)
+	}
+}
@@ -0,0 +1,216 @@
+package bilateral
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mdouchement/bilateral/internal/util"
+	"gonum.org/v1/gonum/mat"
+)
+
+// A Volume filter is a bilateral filter for 3-D volumes, like CT/MRI scans or microscopy stacks.
+// It builds a 4-D grid: x, y, z and intensity.
+type Volume struct {
+	// Voxels holds the volume's intensities, slice by slice and row by row.
+	// The voxel at (x, y, z) is Voxels[(z*Height+y)*Width + x].
+	Voxels     []float32
+	Width      int
+	Height     int
+	Depth      int
+	SigmaRange float64
+	SigmaSpace float64
+	// SigmaSpaceZ, when positive, is the spatial sigma along the z axis (e.g. for thick slices)
+	// and SigmaSpace is only used along the x and y axes.
+	SigmaSpaceZ float64
+	// Kernels holds the blur kernel of each grid axis (x, y, z, then the intensity).
+	// Missing or zero kernels fall back to BinomialKernel.
+	Kernels []Kernel
+	min     float64
+	max     float64
+	// Grid size: width, height, depth and intensity.
+	size []int
+	grid *grid
+}
+
+// NewVolume instanciates a new Volume filter of the given voxels.
+// It returns ErrMapSize if the voxels do not match the given size.
+func NewVolume(voxels []float32, width, height, depth int, sigmaSpace, sigmaRange float64) (*Volume, error) {
+	if width < 0 || height < 0 || depth < 0 || len(voxels) != width*height*depth {
+		return nil, ErrMapSize
+	}
+	return &Volume{
+		Voxels:     voxels,
+		Width:      width,
+		Height:     height,
+		Depth:      depth,
+		SigmaRange: sigmaRange,
+		SigmaSpace: sigmaSpace,
+		size:       make([]int, 4),
+	}, nil
+}
+
+// NewVolumeFromSlices instanciates a new Volume filter of the given stack of slices.
+// The intensities are the slices' gray levels in [0, 1].
+// It returns ErrMapSize if the slices do not have the same size.
+func NewVolumeFromSlices(slices []image.Image, sigmaSpace, sigmaRange float64) (*Volume, error) {
+	var d image.Rectangle
+	if len(slices) > 0 {
+		d = slices[0].Bounds()
+	}
+
+	voxels := make([]float32, 0, d.Dx()*d.Dy()*len(slices))
+	for _, m := range slices {
+		if m.Bounds().Size() != d.Size() {
+			return nil, ErrMapSize
+		}
+
+		sd := m.Bounds()
+		for y := sd.Min.Y; y < sd.Max.Y; y++ {
+			for x := sd.Min.X; x < sd.Max.X; x++ {
+				if fm, ok := m.(*FloatMap); ok {
+					voxels = append(voxels, fm.FloatAt(x, y))
+					continue
+				}
+				gray := color.Gray16Model.Convert(m.At(x, y)).(color.Gray16)
+				voxels = append(voxels, float32(util.Color(uint32(gray.Y))))
+			}
+		}
+	}
+	return NewVolume(voxels, d.Dx(), d.Dy(), len(slices), sigmaSpace, sigmaRange)
+}
+
+// NewVolumeFromGray16 instanciates a new Volume filter of the given stack of 16-bit slices, like the ones
+// returned by ResultSlices. The intensities are the slices' gray levels in [0, 1].
+// It returns ErrMapSize if the slices do not have the same size.
+func NewVolumeFromGray16(slices []*image.Gray16, sigmaSpace, sigmaRange float64) (*Volume, error) {
+	var d image.Rectangle
+	if len(slices) > 0 {
+		d = slices[0].Bounds()
+	}
+
+	voxels := make([]float32, 0, d.Dx()*d.Dy()*len(slices))
+	for _, m := range slices {
+		if m.Bounds().Size() != d.Size() {
+			return nil, ErrMapSize
+		}
+
+		sd := m.Bounds()
+		for y := sd.Min.Y; y < sd.Max.Y; y++ {
+			for x := sd.Min.X; x < sd.Max.X; x++ {
+				voxels = append(voxels, float32(util.Color(uint32(m.Gray16At(x, y).Y))))
+			}
+		}
+	}
+	return NewVolume(voxels, d.Dx(), d.Dy(), len(slices), sigmaSpace, sigmaRange)
+}
+
+// Execute runs the bilateral filter.
+// It returns an error wrapping ErrInvalidOption if the sigma values are not positive and finite.
+func (v *Volume) Execute() error {
+	if !positive(v.SigmaSpace) || !positive(v.SigmaRange) {
+		return fmt.Errorf("%w: volume sigmas must be positive and finite, got %v and %v", ErrInvalidOption, v.SigmaSpace, v.SigmaRange)
+	}
+	if math.IsInf(v.SigmaSpaceZ, 1) {
+		return fmt.Errorf("%w: sigma space z must be finite", ErrInvalidOption)
+	}
+	if len(v.Voxels) == 0 {
+		return nil
+	}
+
+	v.minmax()
+	v.resize()
+	v.downsampling()
+	v.grid = v.grid.convolve(v.Kernels, 1, nil)
+	return nil
+}
+
+// Result computes the interpolation and returns the filtered voxels, in the Voxels layout.
+func (v *Volume) Result() []float32 {
+	filtered := make([]float32, len(v.Voxels))
+	offset := make([]float64, len(v.size))
+	for z := 0; z < v.Depth; z++ {
+		for y := 0; y < v.Height; y++ {
+			for x := 0; x < v.Width; x++ {
+				i := (z*v.Height+y)*v.Width + x
+				offset[0] = float64(x)/v.SigmaSpace + paddingS
+				offset[1] = float64(y)/v.SigmaSpace + paddingS
+				offset[2] = float64(z)/v.sigmaSpaceZ() + paddingS
+				offset[3] = v.rangeCoord(v.Voxels[i]) + paddingR
+
+				c := v.grid.linear(1, offset...)
+				filtered[i] = float32(c.colors.AtVec(0) / c.threshold)
+			}
+		}
+	}
+	return filtered
+}
+
+// ResultSlices computes the interpolation and returns the filtered slices.
+// The intensities are clamped to [0, 1].
+func (v *Volume) ResultSlices() []*image.Gray16 {
+	filtered := v.Result()
+	slices := make([]*image.Gray16, v.Depth)
+	for z := range slices {
+		slices[z] = image.NewGray16(image.Rect(0, 0, v.Width, v.Height))
+		for y := 0; y < v.Height; y++ {
+			for x := 0; x < v.Width; x++ {
+				value := util.Clampf(0, 1, float64(filtered[(z*v.Height+y)*v.Width+x]))
+				slices[z].SetGray16(x, y, color.Gray16{Y: uint16(value*util.MaxRange + 0.5)})
+			}
+		}
+	}
+	return slices
+}
+
+func (v *Volume) minmax() {
+	v.min = math.Inf(1)
+	v.max = math.Inf(-1)
+	for _, i := range v.Voxels {
+		v.min = math.Min(v.min, float64(i))
+		v.max = math.Max(v.max, float64(i))
+	}
+}
+
+// resize computes the grid size from the sigma values.
+func (v *Volume) resize() {
+	v.size[0] = int(float64(v.Width-1)/v.SigmaSpace) + 1 + 2*paddingS
+	v.size[1] = int(float64(v.Height-1)/v.SigmaSpace) + 1 + 2*paddingS
+	v.size[2] = int(float64(v.Depth-1)/v.sigmaSpaceZ()) + 1 + 2*paddingS
+	v.size[3] = int((v.max-v.min)/v.SigmaRange) + 1 + 2*paddingR
+}
+
+func (v *Volume) downsampling() {
+	v.grid = newGrid(v.size, 1)
+	offset := make([]int, len(v.size))
+	for z := 0; z < v.Depth; z++ {
+		for y := 0; y < v.Height; y++ {
+			for x := 0; x < v.Width; x++ {
+				i := v.Voxels[(z*v.Height+y)*v.Width+x]
+
+				offset[0] = int(float64(x)/v.SigmaSpace+0.5) + paddingS
+				offset[1] = int(float64(y)/v.SigmaSpace+0.5) + paddingS
+				offset[2] = int(float64(z)/v.sigmaSpaceZ()+0.5) + paddingS
+				offset[3] = int(v.rangeCoord(i)+0.5) + paddingR
+
+				c := v.grid.At(offset...)
+				c.colors.AddVec(c.colors, mat.NewVecDense(1, []float64{float64(i)}))
+				c.threshold++
+			}
+		}
+	}
+}
+
+// sigmaSpaceZ returns the spatial sigma along the z axis.
+func (v *Volume) sigmaSpaceZ() float64 {
+	if v.SigmaSpaceZ > 0 {
+		return v.SigmaSpaceZ
+	}
+	return v.SigmaSpace
+}
+
+// rangeCoord returns the unpadded grid coordinate of the intensity i.
+func (v *Volume) rangeCoord(i float32) float64 {
+	return (float64(i) - v.min) / v.SigmaRange
+}
@@ -0,0 +1,121 @@
+package bilateral_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+func TestVolume(t *testing.T) {
+	// Noisy volume whose lower slices are dark and upper slices bright
+	w, h, d := 12, 10, 8
+	voxels := make([]float32, w*h*d)
+	for z := 0; z < d; z++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := float32(100)
+				if z >= d/2 {
+					v = 1000
+				}
+				voxels[(z*h+y)*w+x] = v + 20*float32((x*7+y*13+z*3)%5-2)
+			}
+		}
+	}
+
+	filter, err := bilateral.NewVolume(voxels, w, h, d, 2, 100)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewVolume", nil, err)
+	}
+	if err = filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	filtered := filter.Result()
+	for z := 0; z < d; z++ {
+		expected := 100.0
+		if z >= d/2 {
+			expected = 1000
+		}
+		for y := 2; y < h-2; y++ {
+			for x := 2; x < w-2; x++ {
+				if v := filtered[(z*h+y)*w+x]; math.Abs(float64(v)-expected) > 15 {
+					t.Fatalf("%s(%d,%d,%d): expected: %v, actual: %v", "Result", x, y, z, expected, v)
+				}
+			}
+		}
+	}
+
+	if _, err = bilateral.NewVolume(voxels[1:], w, h, d, 2, 100); err != bilateral.ErrMapSize {
+		t.Errorf("%s: expected: %v, actual: %v", "NewVolume", bilateral.ErrMapSize, err)
+	}
+}
+
+func TestVolumeFromSlices(t *testing.T) {
+	var slices []image.Image
+	for z := 0; z < 4; z++ {
+		m := image.NewGray16(image.Rect(0, 0, 6, 5))
+		for i := 0; i < 6*5; i++ {
+			m.SetGray16(i%6, i/6, color.Gray16{Y: uint16(z * 10000)})
+		}
+		slices = append(slices, m)
+	}
+
+	filter, err := bilateral.NewVolumeFromSlices(slices, 2, 0.05)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewVolumeFromSlices", nil, err)
+	}
+	if err = filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	// Slices farther apart than the range sigma are kept
+	result := filter.ResultSlices()
+	for z, m := range result {
+		if v := int(m.Gray16At(3, 2).Y); math.Abs(float64(v-z*10000)) > 500 {
+			t.Errorf("%s(%d): expected: %v, actual: %v", "ResultSlices", z, z*10000, v)
+		}
+	}
+
+	// The filtered slices are a volume of their own
+	typed, err := bilateral.NewVolumeFromGray16(result, 2, 0.05)
+	if err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "NewVolumeFromGray16", nil, err)
+	}
+	for i, v := range filter.Result() {
+		if math.Abs(float64(v-typed.Voxels[i])) > 1.0/65535 {
+			t.Fatalf("%s[%d]: expected: %v, actual: %v", "Voxels", i, v, typed.Voxels[i])
+		}
+	}
+
+	slices = append(slices, image.NewGray16(image.Rect(0, 0, 1, 1)))
+	if _, err = bilateral.NewVolumeFromSlices(slices, 2, 0.05); err != bilateral.ErrMapSize {
+		t.Errorf("%s: expected: %v, actual: %v", "NewVolumeFromSlices", bilateral.ErrMapSize, err)
+	}
+	result = append(result, image.NewGray16(image.Rect(0, 0, 1, 1)))
+	if _, err = bilateral.NewVolumeFromGray16(result, 2, 0.05); err != bilateral.ErrMapSize {
+		t.Errorf("%s: expected: %v, actual: %v", "NewVolumeFromGray16", bilateral.ErrMapSize, err)
+	}
+}
+
+func TestVolumeInvalidSigmas(t *testing.T) {
+	voxels := make([]float32, 4*4*4)
+
+	for name, sigmas := range map[string][2]float64{
+		"Zero sigma space":     {0, 0.1},
+		"Negative sigma range": {2, -0.1},
+		"NaN sigma range":      {2, math.NaN()},
+		"Infinite sigma space": {math.Inf(1), 0.1},
+	} {
+		filter, err := bilateral.NewVolume(voxels, 4, 4, 4, sigmas[0], sigmas[1])
+		if err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", name, nil, err)
+		}
+		if err = filter.Execute(); !errors.Is(err, bilateral.ErrInvalidOption) {
+			t.Errorf("%s: expected: %v, actual: %v", name, bilateral.ErrInvalidOption, err)
+		}
+	}
+}
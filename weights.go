@@ -0,0 +1,40 @@
+package bilateral
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mdouchement/bilateral/internal/util"
+)
+
+// coverage returns the alpha of the mask at (x, y) in [0, 1], 1 without mask.
+func coverage(mask image.Image, x, y int) float64 {
+	if mask == nil {
+		return 1
+	}
+	_, _, _, a := mask.At(x, y).RGBA()
+	return util.Color(a)
+}
+
+// grayLevel returns the gray level of the map at (x, y), 1 without map.
+// FloatMap values are read without quantization.
+func grayLevel(m image.Image, x, y int) float64 {
+	switch cm := m.(type) {
+	case nil:
+		return 1
+	case *FloatMap:
+		return float64(cm.FloatAt(x, y))
+	}
+	return util.Color(uint32(color.Gray16Model.Convert(m.At(x, y)).(color.Gray16).Y))
+}
+
+// confidence returns the gray level of the confidence map at (x, y) clamped to [0, 1], 1 without map.
+// Negative and NaN confidences do not contribute.
+func confidence(m image.Image, x, y int) float64 {
+	w := grayLevel(m, x, y)
+	if !(w > 0) {
+		return 0
+	}
+	return math.Min(w, 1)
+}
@@ -0,0 +1,129 @@
+package bilateral_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mdouchement/bilateral"
+)
+
+// saturated returns a flat map with saturated values and the confidence map excluding them.
+func saturated() (*bilateral.FloatMap, *bilateral.FloatMap) {
+	d := image.Rect(0, 0, 24, 24)
+	m := bilateral.NewFloatMap(d)
+	confidence := bilateral.NewFloatMap(d)
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 24; x++ {
+			m.SetFloat(x, y, 0.5)
+			confidence.SetFloat(x, y, 1)
+			if x%6 == 2 && y%6 == 2 {
+				m.SetFloat(x, y, 1)
+				confidence.SetFloat(x, y, 0)
+			}
+		}
+	}
+	return m, confidence
+}
+
+func TestFastBilateralConfidence(t *testing.T) {
+	m, confidence := saturated()
+
+	for _, splatting := range []bilateral.Splatting{bilateral.Nearest, bilateral.Tent} {
+		filter := bilateral.New(m, 4, 1)
+		filter.Splatting = splatting
+		filter.Execute()
+		if v := filter.ResultMap().FloatAt(8, 8); v <= 0.51 {
+			t.Errorf("%s: expected: %v, actual: %v", splatting.String()+" unweighted", "> 0.51", v)
+		}
+
+		filter = bilateral.New(m, 4, 1)
+		filter.Splatting = splatting
+		filter.Confidence = confidence
+		if err := filter.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+		}
+		if v := filter.ResultMap().FloatAt(8, 8); math.Abs(float64(v)-0.5) > 1e-6 {
+			t.Errorf("%s: expected: %v, actual: %v", splatting.String()+" weighted", 0.5, v)
+		}
+	}
+}
+
+func TestFastBilateralConfidenceClamped(t *testing.T) {
+	m, confidence := saturated()
+
+	// Same weights out of [0, 1]
+	unclamped := bilateral.NewFloatMap(confidence.Bounds())
+	d := confidence.Bounds()
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		for x := d.Min.X; x < d.Max.X; x++ {
+			unclamped.SetFloat(x, y, 4*confidence.FloatAt(x, y)-2)
+		}
+	}
+
+	for _, splatting := range []bilateral.Splatting{bilateral.Nearest, bilateral.Tent} {
+		filter := bilateral.New(m, 4, 1)
+		filter.Splatting = splatting
+		filter.Confidence = confidence
+		filter.Execute()
+		expected := filter.ResultMap()
+
+		filter = bilateral.New(m, 4, 1)
+		filter.Splatting = splatting
+		filter.Confidence = unclamped
+		if err := filter.Execute(); err != nil {
+			t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+		}
+		if actual := filter.ResultMap(); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected: %v, actual: %v", splatting.String()+" clamped", expected.FloatAt(8, 8), actual.FloatAt(8, 8))
+		}
+	}
+}
+
+// hole returns a map stepping from 0.3 to 0.7 at x = 24, with a 24x24 hole of zero values
+// wider than the blur support, and the confidence map excluding the hole.
+func hole() (*bilateral.FloatMap, *bilateral.FloatMap) {
+	d := image.Rect(0, 0, 48, 48)
+	m := bilateral.NewFloatMap(d)
+	confidence := bilateral.NewFloatMap(d)
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 48; x++ {
+			m.SetFloat(x, y, 0.3)
+			if x >= 24 {
+				m.SetFloat(x, y, 0.7)
+			}
+			confidence.SetFloat(x, y, 1)
+			if x >= 12 && x < 36 && y >= 12 && y < 36 {
+				m.SetFloat(x, y, 0)
+				confidence.SetFloat(x, y, 0)
+			}
+		}
+	}
+	return m, confidence
+}
+
+func TestFastBilateralConfidenceHole(t *testing.T) {
+	m, confidence := hole()
+
+	filter := bilateral.New(m, 2, 0.1)
+	filter.Confidence = confidence
+	if err := filter.Execute(); err != nil {
+		t.Fatalf("%s: expected: %v, actual: %v", "Execute", nil, err)
+	}
+
+	// The hole is wider than the blur support, it is filled from the nearest valid pixels
+	result := filter.ResultMap()
+	for _, p := range []struct {
+		x, y     int
+		expected float64
+	}{{14, 24, 0.3}, {20, 24, 0.3}, {27, 24, 0.7}, {33, 24, 0.7}} {
+		if v := float64(result.FloatAt(p.x, p.y)); math.IsNaN(v) || math.Abs(v-p.expected) > 0.02 {
+			t.Errorf("%s(%d,%d): expected: %v, actual: %v", "ResultMap", p.x, p.y, p.expected, v)
+		}
+	}
+	if c := filter.At(14, 24).(color.RGBA); c.R < 70 || c.R > 80 {
+		t.Errorf("%s: expected: %v, actual: %v", "At", 0.3*255, c.R)
+	}
+}